@@ -5,6 +5,8 @@ package dot
 
 import (
 	"reflect"
+	"strings"
+	"sync"
 )
 
 //TypeId dot type guid
@@ -218,3 +220,31 @@ const (
 	//TagDot tag dot
 	TagDot = "dot"
 )
+
+//TagInjecter make the value of the field which tag is "prefix:name", sample: `dot:"grpc:hi"`
+//t is the type of the field, the returned value must be assignable to it
+type TagInjecter = func(l Line, t reflect.Type, name string) (interface{}, error)
+
+var (
+	tagInjecters      = make(map[string]TagInjecter)
+	tagInjectersMutex sync.RWMutex
+)
+
+//RegisterTagInjecter register the injecter of the tag prefix(sample: "grpc"), call it in init()
+func RegisterTagInjecter(prefix string, injecter TagInjecter) {
+	tagInjectersMutex.Lock()
+	defer tagInjectersMutex.Unlock()
+	tagInjecters[prefix] = injecter
+}
+
+//GetTagInjecter return the injecter and the name of the tag(sample: "grpc:hi"), false if the prefix is not registered
+func GetTagInjecter(tag string) (TagInjecter, string, bool) {
+	index := strings.Index(tag, ":")
+	if index < 1 {
+		return nil, "", false
+	}
+	tagInjectersMutex.RLock()
+	defer tagInjectersMutex.RUnlock()
+	injecter, ok := tagInjecters[tag[:index]]
+	return injecter, tag[index+1:], ok
+}
@@ -13,6 +13,7 @@ type Injecter interface {
 	//obj only support structure
 	//dot.TagDot (dot) tag is in the field
 	//If tag is empty, then input with field type, otherwise input with tag value（dot.LiveId）
+	//If tag is "prefix:name" and the prefix is registered(see RegisterTagInjecter), then input with the TagInjecter
 	//In the process if error occurred, it will not quit, returned error is the first one occurred
 	Inject(obj interface{}) error
 	//GetByType get by type
@@ -92,6 +93,12 @@ type Line interface {
 	InfoAllTypeAdnLives()
 }
 
+//LivesLine the line can list its lives, use it by the type assertion of the Line
+type LivesLine interface {
+	//Lives return all lives of the line in the order of dependence, the lives in a circle are at the end
+	Lives() []*Live
+}
+
 // If component need to know current line, then realize this API, and this API Will be called before component Create
 type SetterLine interface {
 	SetLine(l Line)
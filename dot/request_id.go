@@ -0,0 +1,20 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package dot
+
+import "context"
+
+//the key of request id in the context
+type requestIdKey struct{}
+
+//WithRequestId return the context with the request id, sample: the access log of gindot sets it, the conns read it
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+//RequestId return the request id of the context, "" if no request id
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
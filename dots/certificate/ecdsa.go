@@ -187,6 +187,32 @@ func (c *Ecdsa) GenerateCertKey(caParent *x509.Certificate, caPri *ecdsa.Private
 	return err
 }
 
+//Generate the crl(certificate revocation list) of the revoked serial numbers, it is signed by the ca
+// crlFile the pem file of the crl, nextUpdate the time that the next crl will be issued
+func (c *Ecdsa) GenerateCrl(ca *x509.Certificate, caPri *ecdsa.PrivateKey, crlFile string, revoked []*big.Int, nextUpdate time.Time) (err error) {
+	now := time.Now()
+	certs := make([]pkix.RevokedCertificate, 0, len(revoked))
+	for _, it := range revoked {
+		certs = append(certs, pkix.RevokedCertificate{SerialNumber: it, RevocationTime: now})
+	}
+	crlBytes, err := ca.CreateCRL(rand.Reader, caPri, certs, now, nextUpdate)
+	if err != nil {
+		return err
+	}
+
+	file := ""
+	file, err = exPathFileAndMakeDirs(crlFile)
+	if err != nil {
+		return err
+	}
+	crlOut, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer crlOut.Close()
+	return pem.Encode(crlOut, &pem.Block{Type: "X509 CRL", Bytes: crlBytes})
+}
+
 //Read private key from keyFile
 func (c *Ecdsa) PrivateKey(keyFile string) (pri *ecdsa.PrivateKey, err error) {
 
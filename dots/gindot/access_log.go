@@ -0,0 +1,129 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gindot
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scryinfo/dot/dot"
+)
+
+const (
+	//RequestIdKey the key of request id in gin.Context, sample: ctx.GetString(gindot.RequestIdKey)
+	RequestIdKey = "requestId"
+)
+
+//RequestId return the request id, the ctx is the *gin.Context or the context of the request(ctx.Request.Context(), see dot.RequestId), "" if no request id
+func RequestId(ctx context.Context) string {
+	if c, ok := ctx.(*gin.Context); ok {
+		return c.GetString(RequestIdKey)
+	}
+	return dot.RequestId(ctx)
+}
+
+type configAccessLog struct {
+	Structured      bool     `json:"structured"`      //log the zap fields, not the text line
+	RequestIdHeader string   `json:"requestIdHeader"` //if it is set or structured, use the request id(see RequestId), the default value is "X-Request-Id", if the request do not have it, make a new one
+	Headers         bool     `json:"headers"`         //log the request and response headers, only for structured
+	RedactHeaders   []string `json:"redactHeaders"`   //the values are replaced, the default is ["Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"]
+	RequestBody     bool     `json:"requestBody"`     //log the request body, only for structured
+	ResponseBody    bool     `json:"responseBody"`    //log the response body, only for structured
+	MaxBodySize     int      `json:"maxBodySize"`     //max size of the logged body, the default value is 4096
+	SuccessSample   int      `json:"successSample"`   //log one of every SuccessSample 2xx responses, 0 or 1 means log all
+	SlowThreshold   int      `json:"slowThreshold"`   //millisecond, log at warn level if the latency is bigger, 0 means disable
+}
+
+func makeRedactHeaders(headers []string) map[string]bool {
+	if len(headers) < 1 {
+		headers = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+	}
+	redact := make(map[string]bool, len(headers))
+	for _, it := range headers {
+		redact[http.CanonicalHeaderKey(it)] = true
+	}
+	return redact
+}
+
+func redactHeaders(header http.Header, redact map[string]bool) map[string]string {
+	res := make(map[string]string, len(header))
+	for k, v := range header {
+		if redact[k] {
+			res[k] = "***"
+		} else if len(v) == 1 {
+			res[k] = v[0]
+		} else {
+			res[k] = fmt.Sprint(v)
+		}
+	}
+	return res
+}
+
+func newRequestId() string {
+	bs := make([]byte, 16)
+	_, _ = rand.Read(bs)
+	return hex.EncodeToString(bs)
+}
+
+//keep the first max bytes
+type limitBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (c *limitBuffer) Write(p []byte) (int, error) {
+	if remain := c.max - c.buf.Len(); remain < len(p) {
+		c.truncated = true
+		if remain > 0 {
+			c.buf.Write(p[:remain])
+		}
+	} else {
+		c.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+func (c *limitBuffer) String() string {
+	if c.truncated {
+		return c.buf.String() + "...(truncated)"
+	}
+	return c.buf.String()
+}
+
+//capture the request body when the handler read it
+type captureBody struct {
+	io.ReadCloser
+	buf *limitBuffer
+}
+
+func (c *captureBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 {
+		_, _ = c.buf.Write(p[:n])
+	}
+	return n, err
+}
+
+//capture the response body
+type captureWriter struct {
+	gin.ResponseWriter
+	buf *limitBuffer
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	_, _ = c.buf.Write(p)
+	return c.ResponseWriter.Write(p)
+}
+
+func (c *captureWriter) WriteString(s string) (int, error) {
+	_, _ = c.buf.Write([]byte(s))
+	return c.ResponseWriter.WriteString(s)
+}
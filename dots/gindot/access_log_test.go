@@ -0,0 +1,126 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gindot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scryinfo/dot/dot"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type testLogEntry struct {
+	level  string
+	msg    string
+	fields map[string]interface{}
+}
+
+//record the access logs
+type testAccessLogger struct {
+	dot.SLogger
+	entries []testLogEntry
+}
+
+func (c *testAccessLogger) add(level string, msg string, fields []zap.Field) {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, it := range fields {
+		it.AddTo(enc)
+	}
+	c.entries = append(c.entries, testLogEntry{level: level, msg: msg, fields: enc.Fields})
+}
+
+func (c *testAccessLogger) Infoln(msg string, fields ...zap.Field) { c.add("info", msg, fields) }
+func (c *testAccessLogger) Warnln(msg string, fields ...zap.Field) { c.add("warn", msg, fields) }
+func (c *testAccessLogger) Info(m dot.MakeStringer)                { c.add("info", m(), nil) }
+func (c *testAccessLogger) Warn(m dot.MakeStringer)                { c.add("warn", m(), nil) }
+
+func TestEngine_AccessLog(t *testing.T) {
+	type request struct {
+		path, body string
+		header     map[string]string
+	}
+	cases := []struct {
+		name     string
+		conf     configAccessLog
+		requests []request
+		check    func(logs []testLogEntry, res *httptest.ResponseRecorder) bool
+	}{
+		{"text line, no request id", configAccessLog{}, []request{{path: "/ok"}},
+			func(logs []testLogEntry, res *httptest.ResponseRecorder) bool {
+				return len(logs) == 1 && logs[0].fields["requestId"] == nil && len(res.Header().Get("X-Request-Id")) < 1
+			}},
+		{"request id", configAccessLog{Structured: true}, []request{{path: "/ok", header: map[string]string{"X-Request-Id": "id1"}}},
+			func(logs []testLogEntry, res *httptest.ResponseRecorder) bool {
+				return len(logs) == 1 && logs[0].fields["requestId"] == "id1" && res.Header().Get("X-Request-Id") == "id1" && res.Body.String() == "id1"
+			}},
+		{"new request id of the header", configAccessLog{RequestIdHeader: "X-Trace"}, []request{{path: "/ok"}},
+			func(logs []testLogEntry, res *httptest.ResponseRecorder) bool {
+				return len(res.Header().Get("X-Trace")) == 32 && res.Body.String() == res.Header().Get("X-Trace")
+			}},
+		{"sample the success", configAccessLog{Structured: true, SuccessSample: 3}, []request{{path: "/ok"}, {path: "/ok"}, {path: "/ok"}, {path: "/ok"}, {path: "/bad"}},
+			func(logs []testLogEntry, res *httptest.ResponseRecorder) bool {
+				return len(logs) == 3 && logs[2].fields["status"] == int64(http.StatusBadRequest) //the 1st, 4th and the error
+			}},
+		{"redact the headers", configAccessLog{Structured: true, Headers: true}, []request{{path: "/ok", header: map[string]string{"Authorization": "Bearer t", "Accept": "text/plain"}}},
+			func(logs []testLogEntry, res *httptest.ResponseRecorder) bool {
+				req := logs[0].fields["headers"].(map[string]string)
+				resp := logs[0].fields["responseHeaders"].(map[string]string)
+				return req["Authorization"] == "***" && req["Accept"] == "text/plain" && resp["Set-Cookie"] == "***" && resp["X-Test"] == "test"
+			}},
+		{"capture the bodies", configAccessLog{Structured: true, RequestBody: true, ResponseBody: true, MaxBodySize: 4}, []request{{path: "/echo", body: "hello"}},
+			func(logs []testLogEntry, res *httptest.ResponseRecorder) bool {
+				return res.Body.String() == "hello" && logs[0].fields["requestBody"] == "hell...(truncated)" && logs[0].fields["responseBody"] == "hell...(truncated)"
+			}},
+		{"slow", configAccessLog{Structured: true, SlowThreshold: 20, SuccessSample: 100}, []request{{path: "/ok"}, {path: "/slow"}},
+			func(logs []testLogEntry, res *httptest.ResponseRecorder) bool {
+				return len(logs) == 2 && logs[0].level == "info" && logs[1].level == "warn" && logs[1].msg == "[GIN] slow"
+			}},
+	}
+
+	for _, it := range cases {
+		logger := &testAccessLogger{}
+		e := &Engine{config: configEngine{AccessLog: it.conf}, loggerOnlyGin: logger}
+		g := gin.New()
+		g.Use(e.makeLogger(nil))
+		g.GET("/ok", func(ctx *gin.Context) {
+			ctx.Header("Set-Cookie", "s=1")
+			ctx.Header("X-Test", "test")
+			ctx.String(http.StatusOK, RequestId(ctx.Request.Context()))
+		})
+		g.GET("/bad", func(ctx *gin.Context) {
+			ctx.Status(http.StatusBadRequest)
+		})
+		g.GET("/slow", func(ctx *gin.Context) {
+			time.Sleep(30 * time.Millisecond)
+		})
+		g.POST("/echo", func(ctx *gin.Context) {
+			body, _ := ioutil.ReadAll(ctx.Request.Body)
+			ctx.String(http.StatusOK, string(body))
+		})
+
+		var res *httptest.ResponseRecorder
+		for _, r := range it.requests {
+			method := http.MethodGet
+			if len(r.body) > 0 {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, r.path, strings.NewReader(r.body))
+			for k, v := range r.header {
+				req.Header.Set(k, v)
+			}
+			res = httptest.NewRecorder()
+			g.ServeHTTP(res, req)
+		}
+		if !it.check(logger.entries, res) {
+			t.Error(it.name, logger.entries, res.Header(), res.Body.String())
+		}
+	}
+}
@@ -4,15 +4,19 @@
 package gindot
 
 import (
+	"crypto/tls"
 	"fmt"
-	"os"
-	"path/filepath"
+	"net/http"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/scryinfo/dot/dot"
-	"github.com/scryinfo/scryg/sutils/sfile"
+	"github.com/scryinfo/dot/dots/tlsdot"
+	"go.uber.org/zap"
 )
 
 const (
@@ -23,10 +27,16 @@ const (
 )
 
 type configEngine struct {
-	Addr         string   `json:"addr"`         // addr smaple:  ":8080"
-	KeyFile      string   `json:"keyFile"`      //if it is not abs path, preferred to use the executable path
-	PemFile      string   `json:"pemFile"`      //if it is not abs path, preferred to use the executable path
-	LogSkipPaths []string `json:"logSkipPaths"` // not write info log, sample: ["/tt", "/other"]
+	Addr         string           `json:"addr"`         // addr smaple:  ":8080"
+	NoListen     bool             `json:"noListen"`     //do not listen the addr, the engine is served by the other dot, sample: the mux of gserver
+	KeyFile      string           `json:"keyFile"`      //if it is not abs path, preferred to use the executable path
+	PemFile      string           `json:"pemFile"`      //if it is not abs path, preferred to use the executable path
+	Tls          tlsdot.TlsConfig `json:"tls"`          //the mode, min version, sni and client certificate..., the errors fail the Create. if the mode and pem of it are empty, use the KeyFile and PemFile, their errors are only logged and the engine does not listen
+	LogSkipPaths []string         `json:"logSkipPaths"` // not write info log, sample: ["/tt", "/other"]
+	OpenApi      configOpenApi    `json:"openApi"`      // serve the openapi document of all routes
+	Statics      []configStatic   `json:"statics"`      // serve the static files or single page app
+	Stream       configStream     `json:"stream"`       // websocket and server-sent events
+	AccessLog    configAccessLog  `json:"accessLog"`    // options of the access log
 }
 
 //GinEngine  gin dot
@@ -34,28 +44,58 @@ type Engine struct {
 	ginEngine     *gin.Engine
 	config        configEngine
 	loggerOnlyGin dot.SLogger
+	liveId        dot.LiveId
+	line          dot.Line //find the live ids of the controllers
+
+	routes      map[string]*RouteInfo //key: method + " " + path, the routes registered by controller
+	routesMutex sync.Mutex
+
+	staticFs    map[string]http.FileSystem //file systems added by AddStaticFs
+	apiPrefixes []string                   //prefixes of the routers, they are not static files
+	staticMutex sync.Mutex
+
+	upgrader     websocket.Upgrader
+	streams      map[streamCloser]bool //open websocket and sse connections
+	streamsMutex sync.Mutex
+
+	Tls       *tlsdot.Tls `dot:""` //the tls of the line, see tlsdot.Get
+	tlsConfig *tls.Config //nil if no tls
+	tlsFailed bool        //the KeyFile or PemFile can not be loaded, do not listen
+
+	routePaths routePaths //see FullPath
 }
 
 //DefaultGinEngine return the default gin dot,
 //it have to call after the line ceated
 func DefaultGinEngine() *gin.Engine {
+	if g := GetEngine(nil, EngineLiveId); g != nil {
+		return g.ginEngine
+	}
+	return nil
+}
+
+//GetEngine return the gin dot of the live id, if the l is nil, use the default line
+//it have to call after the line ceated
+func GetEngine(l dot.Line, lid dot.LiveId) *Engine {
 	logger := dot.Logger()
-	l := dot.GetDefaultLine()
+	if l == nil {
+		l = dot.GetDefaultLine()
+	}
 	if l == nil {
 		logger.Errorln("the line do not create, do not call it")
 		return nil
 	}
-	d, err := l.ToInjecter().GetByLiveId(EngineLiveId)
+	d, err := l.ToInjecter().GetByLiveId(lid)
 	if err != nil {
 		logger.Errorln(err.Error())
 		return nil
 	}
 
 	if g, ok := d.(*Engine); ok {
-		return g.ginEngine
+		return g
 	}
 
-	logger.Errorln("do not get the gin dot")
+	logger.Errorln("do not get the gin dot, live id: " + lid.String())
 	return nil
 }
 
@@ -88,6 +128,16 @@ func TypeLiveGinDot() *dot.TypeLives {
 	}
 }
 
+//TypeLiveGinDotWith generate data for structural  dot, the lives are liveIds,
+//every live is an independent gin engine with its own config(addr ...)
+func TypeLiveGinDotWith(liveIds ...dot.LiveId) *dot.TypeLives {
+	tl := TypeLiveGinDot()
+	for _, lid := range liveIds {
+		tl.Lives = append(tl.Lives, dot.Live{LiveId: lid})
+	}
+	return tl
+}
+
 //jayce edit
 //return config of GinDot
 func ConfigTypeLiveGinDot() *dot.ConfigTypeLives {
@@ -101,17 +151,57 @@ func ConfigTypeLiveGinDot() *dot.ConfigTypeLives {
 	}
 }
 
+func (c *Engine) SetTypeId(tid dot.TypeId, lid dot.LiveId) {
+	c.liveId = lid
+}
+
+//LiveId return the live id of the gin dot
+func (c *Engine) LiveId() dot.LiveId {
+	return c.liveId
+}
+
 //Create create the gin
 func (c *Engine) Create(l dot.Line) error {
+	c.line = l
+	if c.Tls == nil {
+		c.Tls = tlsdot.Get(l)
+	}
 	c.ginEngine = gin.New()
 	c.loggerOnlyGin = dot.Logger().NewLogger(1)
-	c.ginEngine.Use(c.makeLogger(l), gin.Recovery())
-	return nil
+	c.ginEngine.Use(func(ctx *gin.Context) {
+		ctx.Set(engineKey, c)
+	}, c.makeLogger(l), gin.Recovery())
+	c.serveOpenApi()
+	c.serveStatic()
+	c.initStream()
+	//the key pair is reloaded when the files change, if the path is not abs, preferred to use the executable path
+	var err error
+	conf := c.config.Tls
+	if len(conf.Pem) < 1 && len(conf.Mode) < 1 { //the old KeyFile and PemFile, the error is only logged as before
+		if len(c.config.KeyFile) > 0 && len(c.config.PemFile) > 0 {
+			conf.Pem, conf.Key = c.config.PemFile, c.config.KeyFile
+			if c.tlsConfig, err = c.Tls.ServerConfig(conf); err != nil {
+				dot.Logger().Errorln("Engine", zap.String("", "the keyfile or pemfile can not be loaded, do not listen"), zap.Error(err))
+				c.tlsFailed = true
+			}
+		}
+		return nil
+	}
+	c.tlsConfig, err = c.Tls.ServerConfig(conf)
+	return err
 }
 
 //AfterAllStart run the function after start
 func (c *Engine) AfterAllStart(l dot.Line) {
-	go c.startServer()
+	if !c.config.NoListen {
+		go c.startServer()
+	}
+}
+
+//Stop close all websocket and sse connections
+func (c *Engine) Stop(ignore bool) error {
+	c.closeStreams()
+	return nil
 }
 
 func (c *Engine) GinEngine() *gin.Engine {
@@ -121,61 +211,38 @@ func (c *Engine) GinEngine() *gin.Engine {
 //all post
 func (c *Engine) RouterPost(h interface{}, pre string) {
 	post := reflect.ValueOf(c.ginEngine).MethodByName("POST")
-	RouterSelf(h, pre, func(url string, gmethod reflect.Value) {
+	routerSelf(h, pre, func(url string, name string, gmethod reflect.Value) {
 		vs := []reflect.Value{reflect.ValueOf(url), gmethod}
 		post.Call(vs)
+		c.addRoute(h, http.MethodPost, url, name)
 	})
 }
 
 //all get
 func (c *Engine) RouterGet(h interface{}, pre string) {
 	get := reflect.ValueOf(c.ginEngine).MethodByName("GET")
-	RouterSelf(h, pre, func(url string, gmethod reflect.Value) {
+	routerSelf(h, pre, func(url string, name string, gmethod reflect.Value) {
 		vs := []reflect.Value{reflect.ValueOf(url), gmethod}
 		get.Call(vs)
+		c.addRoute(h, http.MethodGet, url, name)
 	})
 }
 
 func (c *Engine) startServer() {
 	llog := dot.Logger() //do not use the c.loggerOnlyGin, it only for gin
-	if len(c.config.KeyFile) > 0 && len(c.config.PemFile) > 0 {
-		keyFile := c.config.KeyFile
-		pemFile := c.config.PemFile
-		{
-			ex, err := os.Executable()
-			if err == nil {
-				ex = filepath.Dir(ex)
-			} else {
-				ex = ""
-			}
-			if !filepath.IsAbs(keyFile) { //preferred to use the executable path
-				t := filepath.Join(ex, keyFile)
-				if sfile.ExistFile(t) {
-					keyFile = t
-				}
-			}
-
-			if !filepath.IsAbs(pemFile) { //preferred to use the executable path
-				t := filepath.Join(ex, pemFile)
-				if sfile.ExistFile(t) {
-					pemFile = t
-				}
-			}
-			if sfile.ExistFile(pemFile) && sfile.ExistFile(keyFile) {
-				err := c.ginEngine.RunTLS(c.config.Addr, pemFile, keyFile)
-				if err != nil {
-					llog.Errorln(err.Error())
-				}
-			} else {
-				llog.Errorln("the keyfile or pemfile do not exist")
-				return
-			}
-		}
+	if c.tlsFailed {
+		return
+	}
+	server := &http.Server{Addr: c.config.Addr, Handler: c.ginEngine}
+	var err error
+	if c.tlsConfig != nil {
+		server.TLSConfig = c.tlsConfig
+		err = server.ListenAndServeTLS("", "")
 	} else {
-		err := c.ginEngine.Run(c.config.Addr)
-		if err != nil {
-			llog.Errorln(err.Error())
-		}
+		err = server.ListenAndServe()
+	}
+	if err != nil {
+		llog.Errorln(err.Error())
 	}
 }
 
@@ -183,6 +250,7 @@ func (c *Engine) makeLogger(l dot.Line) gin.HandlerFunc {
 
 	formatter := defaultLogFormatter
 	notLogged := c.config.LogSkipPaths
+	conf := c.config.AccessLog
 
 	var skip map[string]struct{}
 
@@ -193,6 +261,16 @@ func (c *Engine) makeLogger(l dot.Line) gin.HandlerFunc {
 			skip[path] = struct{}{}
 		}
 	}
+	withRequestId := conf.Structured || len(conf.RequestIdHeader) > 0 //the text line log does not use the request id
+	if len(conf.RequestIdHeader) < 1 {
+		conf.RequestIdHeader = "X-Request-Id"
+	}
+	if conf.MaxBodySize < 1 {
+		conf.MaxBodySize = 4096
+	}
+	redact := makeRedactHeaders(conf.RedactHeaders)
+	slow := time.Duration(conf.SlowThreshold) * time.Millisecond
+	var successCount uint64
 	logger := c.loggerOnlyGin
 
 	return func(c *gin.Context) {
@@ -200,35 +278,105 @@ func (c *Engine) makeLogger(l dot.Line) gin.HandlerFunc {
 		start := time.Now()
 		path := c.Request.URL.Path
 		raw := c.Request.URL.RawQuery
+		_, skipped := skip[path]
+
+		requestId := ""
+		if withRequestId {
+			requestId = c.GetHeader(conf.RequestIdHeader)
+			if len(requestId) < 1 {
+				requestId = newRequestId()
+				c.Request.Header.Set(conf.RequestIdHeader, requestId)
+			}
+			c.Set(RequestIdKey, requestId)
+			c.Request = c.Request.WithContext(dot.WithRequestId(c.Request.Context(), requestId))
+			c.Header(conf.RequestIdHeader, requestId)
+		}
+
+		var reqBody, resBody *limitBuffer
+		if !skipped && conf.RequestBody && c.Request.Body != nil {
+			reqBody = &limitBuffer{max: conf.MaxBodySize}
+			c.Request.Body = &captureBody{ReadCloser: c.Request.Body, buf: reqBody}
+		}
+		if !skipped && conf.ResponseBody {
+			resBody = &limitBuffer{max: conf.MaxBodySize}
+			c.Writer = &captureWriter{ResponseWriter: c.Writer, buf: resBody}
+		}
 
 		// Process request
 		c.Next()
 
 		// Log only when path is not being skipped
-		if _, ok := skip[path]; !ok {
+		if skipped {
+			return
+		}
+		latency := time.Since(start)
+		status := c.Writer.Status()
+		isSlow := slow > 0 && latency >= slow
+		if !isSlow && conf.SuccessSample > 1 && status >= http.StatusOK && status < http.StatusMultipleChoices {
+			if atomic.AddUint64(&successCount, 1)%uint64(conf.SuccessSample) != 1 { //log the first one of every SuccessSample
+				return
+			}
+		}
+		if raw != "" {
+			path = path + "?" + raw
+		}
+
+		if !conf.Structured {
 			param := gin.LogFormatterParams{
 				Request: c.Request,
 				Keys:    c.Keys,
 			}
 
 			// Stop timer
-			param.TimeStamp = time.Now()
-			param.Latency = param.TimeStamp.Sub(start)
+			param.TimeStamp = start.Add(latency)
+			param.Latency = latency
 
 			param.ClientIP = c.ClientIP()
 			param.Method = c.Request.Method
-			param.StatusCode = c.Writer.Status()
+			param.StatusCode = status
 			param.ErrorMessage = c.Errors.ByType(gin.ErrorTypePrivate).String()
 
 			param.BodySize = c.Writer.Size()
-
-			if raw != "" {
-				path = path + "?" + raw
-			}
 			param.Path = path
-			logger.Info(func() string {
-				return formatter(param)
-			})
+			if isSlow {
+				logger.Warn(func() string {
+					return formatter(param)
+				})
+			} else {
+				logger.Info(func() string {
+					return formatter(param)
+				})
+			}
+			return
+		}
+
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("path", path),
+			zap.Int("status", status),
+			zap.Duration("latency", latency),
+			zap.String("clientIp", c.ClientIP()),
+			zap.Int("bytes", c.Writer.Size()),
+			zap.String("requestId", requestId),
+			zap.String("userAgent", c.Request.UserAgent()),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); len(errs) > 0 {
+			fields = append(fields, zap.String("error", errs))
+		}
+		if conf.Headers {
+			fields = append(fields, zap.Any("headers", redactHeaders(c.Request.Header, redact)),
+				zap.Any("responseHeaders", redactHeaders(c.Writer.Header(), redact)))
+		}
+		if reqBody != nil {
+			fields = append(fields, zap.String("requestBody", reqBody.String()))
+		}
+		if resBody != nil {
+			fields = append(fields, zap.String("responseBody", resBody.String()))
+		}
+		if isSlow {
+			logger.Warnln("[GIN] slow", fields...)
+		} else {
+			logger.Infoln("[GIN]", fields...)
 		}
 	}
 }
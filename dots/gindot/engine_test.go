@@ -0,0 +1,31 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gindot
+
+import (
+	"testing"
+
+	"github.com/scryinfo/dot/dots/tlsdot"
+)
+
+func TestEngine_TlsError(t *testing.T) {
+	for i, it := range []struct {
+		conf   configEngine
+		failed bool //the Create fails
+	}{
+		{configEngine{KeyFile: "none.key", PemFile: "none.pem"}, false}, //the old files, only log
+		{configEngine{KeyFile: "none.key"}, false},                      //no tls
+		{configEngine{Tls: tlsdot.TlsConfig{Mode: tlsdot.TlsModeServer, Pem: "none.pem", Key: "none.key"}}, true},
+		{configEngine{KeyFile: "none.key", PemFile: "none.pem", Tls: tlsdot.TlsConfig{Mode: tlsdot.TlsModeServer}}, true},
+	} {
+		e := &Engine{config: it.conf}
+		err := e.Create(nil)
+		if (err != nil) != it.failed {
+			t.Error(i, err)
+		}
+		if !it.failed && e.tlsFailed != (len(it.conf.PemFile) > 0) {
+			t.Error(i, "the engine listens without the key pair")
+		}
+	}
+}
@@ -3,13 +3,17 @@ module github.com/scryinfo/dot/dots/gindot
 go 1.12
 
 require (
+	github.com/gin-contrib/sse v0.1.0
 	github.com/gin-gonic/gin v1.4.0
+	github.com/gorilla/websocket v1.4.0
 	github.com/mattn/go-isatty v0.0.8 // indirect
+	github.com/pkg/errors v0.8.1
 	github.com/scryinfo/dot v0.1.3-0.20190705064446-6614e45bf155
 	github.com/scryinfo/scryg v0.1.3-0.20190608053141-a292b801bfd6
+	go.uber.org/zap v1.10.0
 	golang.org/x/net v0.0.0-20190522155817-f3200d17e092 // indirect
 	golang.org/x/sys v0.0.0-20190529164535-6a60838ec259 // indirect
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 )
 
-replace github.com/scryinfo/dot v0.0.0 => ../../
+replace github.com/scryinfo/dot => ../../
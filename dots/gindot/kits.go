@@ -15,7 +15,18 @@ import (
 //for each funcs that like “gin.HandlerFunc”
 //sampe pre = "scry", and  "func (c * SampleCtroller) Hello(cxt *gin.Context) {}", the url is "/scry/hello"
 func RouterSelf(h interface{}, pre string, call func(url string, gmethod reflect.Value)) {
-	hf := reflect.TypeOf(gin.HandlerFunc(nil))
+	routerSelf(h, pre, func(url string, name string, gmethod reflect.Value) {
+		call(url, gmethod)
+	})
+}
+
+//same as RouterSelf, name is the method name of the controller
+func routerSelf(h interface{}, pre string, call func(url string, name string, gmethod reflect.Value)) {
+	routerSelfType(h, pre, reflect.TypeOf(gin.HandlerFunc(nil)), call)
+}
+
+//same as routerSelf, for each funcs that like the hf, sample: WsHandlerFunc
+func routerSelfType(h interface{}, pre string, hf reflect.Type, call func(url string, name string, gmethod reflect.Value)) {
 	vr := reflect.ValueOf(h)
 	tr := reflect.TypeOf(h)
 	pre = strings.TrimSpace(pre)
@@ -32,12 +43,13 @@ func RouterSelf(h interface{}, pre string, call func(url string, gmethod reflect
 	for i := 0; i < vr.NumMethod(); i++ {
 		vm := vr.Method(i)
 		if vm.Type().AssignableTo(hf) {
-			lname := strings.ToLower(tr.Method(i).Name)
+			name := tr.Method(i).Name
+			lname := strings.ToLower(name)
 			if lname == "index" {
 				lname = ""
 			}
 			p := pre + lname
-			call(p, vm)
+			call(p, name, vm)
 		}
 	}
 }
@@ -63,10 +75,16 @@ func RouterGet(g *gin.RouterGroup, h interface{}, pre string) {
 //TypeLiveGinDot generate data for structural  dot
 //routerId: is the liveid of  gindot/router component
 func PreAddControlDot(ctype reflect.Type, routerId dot.LiveId) *dot.TypeLives {
+	return PreAddControlDotLive(ctype, dot.LiveId(ctype.Name()), routerId)
+}
+
+//PreAddControlDotLive same as PreAddControlDot, liveId is the liveid of the controller,
+//so one controller type can have lives on different routers(engines)
+func PreAddControlDotLive(ctype reflect.Type, liveId dot.LiveId, routerId dot.LiveId) *dot.TypeLives {
 	tl := &dot.TypeLives{
 		Meta: dot.Metadata{TypeId: dot.TypeId(ctype.Name()), RefType: ctype, NewDoter: nil},
 		Lives: []dot.Live{dot.Live{
-			LiveId:    dot.LiveId(ctype.Name()),
+			LiveId:    liveId,
 			RelyLives: map[string]dot.LiveId{"GinRouter_": routerId},
 		}},
 	}
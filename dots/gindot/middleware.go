@@ -0,0 +1,49 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gindot
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+var (
+	middlewares      = make(map[string]gin.HandlerFunc)
+	middlewaresMutex sync.RWMutex
+)
+
+//RegisterMiddleware the dot register the gin middleware, and then the router use it by the name in config "middlewares"
+//the names are resolved after all inject, so register it in Create or Injected of the dot
+func RegisterMiddleware(name string, middleware gin.HandlerFunc) {
+	middlewaresMutex.Lock()
+	defer middlewaresMutex.Unlock()
+	middlewares[name] = middleware
+}
+
+//GetMiddleware return the registered middleware
+func GetMiddleware(name string) (gin.HandlerFunc, bool) {
+	middlewaresMutex.RLock()
+	defer middlewaresMutex.RUnlock()
+	it, ok := middlewares[name]
+	return it, ok
+}
+
+//return the middlewares of the names in order
+//if one of them is not registered, all requests return 500, do not skip it(it may be the auth)
+func resolveMiddlewares(names []string) ([]gin.HandlerFunc, error) {
+	handlers := make([]gin.HandlerFunc, 0, len(names))
+	for _, name := range names {
+		it, ok := GetMiddleware(name)
+		if !ok {
+			return []gin.HandlerFunc{func(ctx *gin.Context) {
+				ctx.AbortWithStatus(http.StatusInternalServerError)
+			}}, errors.New("the gin middleware is not registered: " + name)
+		}
+		handlers = append(handlers, it)
+	}
+	return handlers, nil
+}
@@ -0,0 +1,81 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gindot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRouter_Middlewares(t *testing.T) {
+	RegisterMiddleware("test", func(ctx *gin.Context) {
+		ctx.Header("X-Test", "test")
+	})
+
+	request := func(middlewares ...string) *httptest.ResponseRecorder {
+		e := &Engine{}
+		if err := e.Create(nil); err != nil {
+			t.Fatal(err)
+		}
+		r := &Router{Engine_: e, config: configRouter{RelativePath: "/api", Middlewares: middlewares}}
+		r.AfterAllInject(nil)
+		r.Router().GET("/hi", func(ctx *gin.Context) {
+			ctx.String(http.StatusOK, "hi")
+		})
+		rec := httptest.NewRecorder()
+		e.GinEngine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/hi", nil))
+		return rec
+	}
+
+	if rec := request("test"); rec.Code != http.StatusOK || rec.Header().Get("X-Test") != "test" {
+		t.Error(rec.Code, rec.Header())
+	}
+	if rec := request("test", "unknown"); rec.Code != http.StatusInternalServerError {
+		t.Error(rec.Code)
+	}
+}
+
+func TestFullPath(t *testing.T) {
+	e := &Engine{}
+	if err := e.Create(nil); err != nil {
+		t.Fatal(err)
+	}
+	full := ""
+	handler := func(ctx *gin.Context) {
+		full = FullPath(ctx)
+	}
+	e.GinEngine().GET("/v1/users/:id", handler)
+	e.GinEngine().GET("/v1/users/:id/books/:book", handler)
+	e.GinEngine().POST("/v1/users", handler)
+	e.GinEngine().GET("/files/*name", handler)
+	e.GinEngine().NoRoute(handler)
+
+	for path, want := range map[string]string{
+		"GET /v1/users/1":          "/v1/users/:id",
+		"GET /v1/users/2/books/x":  "/v1/users/:id/books/:book",
+		"POST /v1/users":           "/v1/users",
+		"GET /files/a/b.txt":       "/files/*name",
+		"GET /v1/users/1/books":    "",
+		"GET /v1/other":            "",
+		"DELETE /v1/users/1/books": "",
+	} {
+		full = "none"
+		parts := strings.SplitN(path, " ", 2)
+		e.GinEngine().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(parts[0], parts[1], nil))
+		if full != want {
+			t.Error(path, full)
+		}
+	}
+	//the route added later is matched after the tree is rebuilt
+	e.GinEngine().GET("/v2/items/:id", handler)
+	e.routePaths.refreshed = 0
+	e.GinEngine().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v2/items/1", nil))
+	if full != "/v2/items/:id" {
+		t.Error(full)
+	}
+}
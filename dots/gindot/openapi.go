@@ -0,0 +1,396 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gindot
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scryinfo/dot/dot"
+)
+
+type configOpenApi struct {
+	Path    string `json:"path"`    //url of the openapi document, sample: "/openapi.json", if it is empty, do not serve the document
+	Title   string `json:"title"`   //title of the api, the default value is "dot"
+	Version string `json:"version"` //version of the api, the default value is "1.0.0"
+}
+
+//RouteInfo one route of the gin engine
+type RouteInfo struct {
+	Method string //http verb, sample: "GET"
+	Path   string //full path, sample: "/sample/hello"
+	//the type of the controller, it is nil if the route is not registered by RouterPost or RouterGet
+	Controller reflect.Type
+	//live id of the controller, it is empty if the controller is not a dot of the line
+	LiveId dot.LiveId
+	//the method name of the controller, sample: "Hello"
+	Name string
+	//request and response types, it is nil if the controller do not declare them
+	Api *ApiType
+
+	controller interface{} //find the LiveId
+}
+
+//ApiType request and response types of one controller method
+//Req and Res can be nil, the struct or the pointer of the struct
+type ApiType struct {
+	Summary string
+	Req     interface{}
+	Res     interface{}
+}
+
+//ApiTyper if the controller implement it, the request and response schemas are in the openapi document
+//the key of the map is the method name, sample: "Hello"
+type ApiTyper interface {
+	ApiTypes() map[string]ApiType
+}
+
+//record the route of controller
+func (c *Engine) addRoute(h interface{}, method string, url string, name string) {
+	route := &RouteInfo{
+		Method:     method,
+		Path:       url,
+		Controller: reflect.TypeOf(h),
+		Name:       name,
+		controller: h,
+	}
+	if at, ok := h.(ApiTyper); ok {
+		if api, ok := at.ApiTypes()[name]; ok {
+			route.Api = &api
+		}
+	}
+
+	c.routesMutex.Lock()
+	if c.routes == nil {
+		c.routes = make(map[string]*RouteInfo)
+	}
+	c.routes[method+" "+url] = route
+	c.routesMutex.Unlock()
+}
+
+//liveIds return the live ids of the dots in the line, key: the dot, it is nil if the line can not list the lives
+func liveIds(l dot.Line) map[interface{}]dot.LiveId {
+	ll, ok := l.(dot.LivesLine)
+	if !ok {
+		return nil
+	}
+	lives := ll.Lives()
+	ids := make(map[interface{}]dot.LiveId, len(lives))
+	for _, it := range lives {
+		if it.Dot != nil && reflect.TypeOf(it.Dot).Kind() == reflect.Ptr { //the other values may be not comparable
+			ids[it.Dot] = it.LiveId
+		}
+	}
+	return ids
+}
+
+//Routes return all routes of the gin engine, include the routes that are not registered by controller
+func (c *Engine) Routes() []RouteInfo {
+	gr := c.ginEngine.Routes()
+	routes := make([]RouteInfo, 0, len(gr))
+	var ids map[interface{}]dot.LiveId //once for all routes
+	idsLoaded := false
+	c.routesMutex.Lock()
+	defer c.routesMutex.Unlock()
+	for _, it := range gr {
+		if r, ok := c.routes[it.Method+" "+it.Path]; ok {
+			route := *r
+			if route.controller != nil && reflect.TypeOf(route.controller).Kind() == reflect.Ptr {
+				if !idsLoaded {
+					ids, idsLoaded = liveIds(c.line), true
+				}
+				route.LiveId = ids[route.controller]
+			}
+			routes = append(routes, route)
+		} else {
+			routes = append(routes, RouteInfo{Method: it.Method, Path: it.Path})
+		}
+	}
+	return routes
+}
+
+func (c *Engine) serveOpenApi() {
+	url := c.config.OpenApi.Path
+	if len(url) < 1 {
+		return
+	}
+	c.ginEngine.GET(url, func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, c.OpenApi())
+	})
+}
+
+//OpenApi make the openapi 3 document of all routes, the document path is not in it
+func (c *Engine) OpenApi() interface{} {
+	conf := c.config.OpenApi
+	doc := &openApiDoc{
+		OpenApi: "3.0.0",
+		Info:    openApiInfo{Title: conf.Title, Version: conf.Version},
+		Paths:   make(map[string]map[string]*openApiOperation),
+	}
+	if len(doc.Info.Title) < 1 {
+		doc.Info.Title = "dot"
+	}
+	if len(doc.Info.Version) < 1 {
+		doc.Info.Version = "1.0.0"
+	}
+	schemas := newOpenApiSchemas()
+
+	for _, r := range c.Routes() {
+		if r.Path == conf.Path && r.Method == http.MethodGet {
+			continue
+		}
+		p, params := openApiPath(r.Path)
+		op := &openApiOperation{
+			Parameters: params,
+			Responses:  map[string]*openApiResponse{"200": {Description: "OK"}},
+		}
+		if r.Controller != nil {
+			t := r.Controller
+			for t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			op.Tags = []string{t.Name()}
+			op.OperationId = t.Name() + "." + r.Name
+		}
+		if r.Api != nil {
+			op.Summary = r.Api.Summary
+			if r.Api.Req != nil {
+				rt := reflect.TypeOf(r.Api.Req)
+				switch r.Method {
+				case http.MethodGet, http.MethodHead, http.MethodDelete:
+					op.Parameters = append(op.Parameters, schemas.queryParameters(rt)...)
+				default:
+					op.RequestBody = &openApiBody{Content: map[string]openApiMedia{
+						gin.MIMEJSON: {Schema: schemas.schemaOf(rt)},
+					}}
+				}
+			}
+			if r.Api.Res != nil {
+				op.Responses["200"].Content = map[string]openApiMedia{
+					gin.MIMEJSON: {Schema: schemas.schemaOf(reflect.TypeOf(r.Api.Res))},
+				}
+			}
+		}
+
+		ops, ok := doc.Paths[p]
+		if !ok {
+			ops = make(map[string]*openApiOperation)
+			doc.Paths[p] = ops
+		}
+		ops[strings.ToLower(r.Method)] = op
+	}
+	if len(schemas.schemas) > 0 {
+		doc.Components = &openApiComponents{Schemas: schemas.schemas}
+	}
+
+	return doc
+}
+
+//convert the gin path to openapi path, sample: "/user/:id/*file" to "/user/{id}/{file}"
+func openApiPath(p string) (string, []openApiParameter) {
+	var params []openApiParameter
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		if len(seg) > 1 && (seg[0] == ':' || seg[0] == '*') {
+			name := seg[1:]
+			segs[i] = "{" + name + "}"
+			params = append(params, openApiParameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &openApiSchema{Type: "string"},
+			})
+		}
+	}
+	return strings.Join(segs, "/"), params
+}
+
+//same as the joinPaths of gin
+func joinPaths(absolutePath, relativePath string) string {
+	if relativePath == "" {
+		return absolutePath
+	}
+
+	finalPath := path.Join(absolutePath, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(finalPath, "/") {
+		return finalPath + "/"
+	}
+	return finalPath
+}
+
+type openApiDoc struct {
+	OpenApi    string                                  `json:"openapi"`
+	Info       openApiInfo                             `json:"info"`
+	Paths      map[string]map[string]*openApiOperation `json:"paths"`
+	Components *openApiComponents                      `json:"components,omitempty"`
+}
+
+type openApiInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openApiComponents struct {
+	Schemas map[string]*openApiSchema `json:"schemas,omitempty"`
+}
+
+type openApiOperation struct {
+	Tags        []string                    `json:"tags,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	OperationId string                      `json:"operationId,omitempty"`
+	Parameters  []openApiParameter          `json:"parameters,omitempty"`
+	RequestBody *openApiBody                `json:"requestBody,omitempty"`
+	Responses   map[string]*openApiResponse `json:"responses"`
+}
+
+type openApiParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *openApiSchema `json:"schema"`
+}
+
+type openApiBody struct {
+	Content map[string]openApiMedia `json:"content"`
+}
+
+type openApiResponse struct {
+	Description string                  `json:"description"`
+	Content     map[string]openApiMedia `json:"content,omitempty"`
+}
+
+type openApiMedia struct {
+	Schema *openApiSchema `json:"schema"`
+}
+
+type openApiSchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Items                *openApiSchema            `json:"items,omitempty"`
+	Properties           map[string]*openApiSchema `json:"properties,omitempty"`
+	AdditionalProperties *openApiSchema            `json:"additionalProperties,omitempty"`
+}
+
+//all named struct schemas of the document, they are referenced by "#/components/schemas/name"
+type openApiSchemas struct {
+	schemas map[string]*openApiSchema
+	names   map[reflect.Type]string
+}
+
+func newOpenApiSchemas() *openApiSchemas {
+	return &openApiSchemas{
+		schemas: make(map[string]*openApiSchema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+func (c *openApiSchemas) schemaOf(t reflect.Type) *openApiSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return &openApiSchema{Type: "string", Format: "date-time"}
+	case reflect.TypeOf([]byte(nil)):
+		return &openApiSchema{Type: "string", Format: "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openApiSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openApiSchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &openApiSchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &openApiSchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &openApiSchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &openApiSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &openApiSchema{Type: "array", Items: c.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &openApiSchema{Type: "object", AdditionalProperties: c.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if len(t.Name()) < 1 { //anonymous struct
+			return c.structSchema(t)
+		}
+		name, ok := c.names[t]
+		if !ok {
+			name = t.Name()
+			for i := 2; c.schemas[name] != nil; i++ { //the same name in different packages
+				name = t.Name() + strconv.Itoa(i)
+			}
+			c.names[t] = name
+			c.schemas[name] = &openApiSchema{} //placeholder for the recursive type
+			*c.schemas[name] = *c.structSchema(t)
+		}
+		return &openApiSchema{Ref: "#/components/schemas/" + name}
+	default: //interface{} and others
+		return &openApiSchema{}
+	}
+}
+
+func (c *openApiSchemas) structSchema(t reflect.Type) *openApiSchema {
+	s := &openApiSchema{Type: "object", Properties: make(map[string]*openApiSchema)}
+	c.eachField(t, "json", func(name string, f reflect.StructField) {
+		s.Properties[name] = c.schemaOf(f.Type)
+	})
+	return s
+}
+
+//the fields of Req are the query parameters, the name is the "form" tag, or the "json" tag
+func (c *openApiSchemas) queryParameters(t reflect.Type) []openApiParameter {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var params []openApiParameter
+	c.eachField(t, "form", func(name string, f reflect.StructField) {
+		params = append(params, openApiParameter{Name: name, In: "query", Schema: c.schemaOf(f.Type)})
+	})
+	return params
+}
+
+//call the do for each exported field, the fields of embedded struct are expanded
+func (c *openApiSchemas) eachField(t reflect.Type, tagName string, do func(name string, f reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup(tagName)
+		if !ok && tagName != "json" {
+			tag = f.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && len(name) < 1 {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				c.eachField(ft, tagName, do)
+				continue
+			}
+		}
+		if len(f.PkgPath) > 0 { //unexported
+			continue
+		}
+		if len(name) < 1 {
+			name = f.Name
+		}
+		do(name, f)
+	}
+}
@@ -0,0 +1,117 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gindot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scryinfo/dot/dot"
+	"github.com/scryinfo/dot/dots/tlsdot"
+)
+
+type openApiReq struct {
+	Name string `json:"name" form:"name"`
+	Age  int    `json:"age"`
+}
+
+type openApiRes struct {
+	Items []openApiReq    `json:"items"`
+	Next  *openApiRes     `json:"next"`
+	Tags  map[string]bool `json:"tags"`
+	skip  string
+}
+
+type openApiCtroller struct {
+	name string //not zero size, the pointers are different
+}
+
+func (c *openApiCtroller) ApiTypes() map[string]ApiType {
+	return map[string]ApiType{
+		"Hello": {Summary: "hello", Req: openApiReq{}, Res: &openApiRes{}},
+	}
+}
+
+func (c *openApiCtroller) Hello(ctx *gin.Context) {}
+
+func (c *openApiCtroller) Index(ctx *gin.Context) {}
+
+//the line lists the lives for the live id of the controller
+type testLivesLine struct {
+	dot.Line
+	lives []*dot.Live
+	calls int
+}
+
+func (c *testLivesLine) Lives() []*dot.Live {
+	c.calls++
+	return c.lives
+}
+
+func TestEngine_OpenApi(t *testing.T) {
+	ctrl := &openApiCtroller{}
+	e := &Engine{config: configEngine{OpenApi: configOpenApi{Path: "/openapi.json"}}, Tls: tlsdot.New()} //the test line has no injecter
+	l := &testLivesLine{lives: []*dot.Live{{LiveId: "ctrlLive", Dot: ctrl}}}
+	if err := e.Create(l); err != nil {
+		t.Fatal(err)
+	}
+	e.RouterPost(ctrl, "sample")
+	e.RouterGet(&openApiCtroller{}, "other") //not a dot of the line
+	e.GinEngine().GET("/user/:id", func(ctx *gin.Context) {})
+
+	routes := e.Routes()
+	if l.calls != 1 {
+		t.Error("the lives are listed for every route", l.calls)
+	}
+	found := false
+	for _, r := range routes {
+		if r.Method == http.MethodPost && r.Path == "/sample/hello" {
+			found = true
+			if r.LiveId != "ctrlLive" || r.Name != "Hello" || r.Api == nil {
+				t.Errorf("route: %v", r)
+			}
+		}
+		if r.Path == "/other/hello" && len(r.LiveId) > 0 {
+			t.Errorf("route: %v", r)
+		}
+	}
+	if !found {
+		t.Fatal("can not find the route /sample/hello")
+	}
+
+	w := httptest.NewRecorder()
+	e.GinEngine().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatal(w.Code)
+	}
+	doc := &openApiDoc{}
+	if err := json.Unmarshal(w.Body.Bytes(), doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := doc.Paths["/openapi.json"]; ok {
+		t.Error("the document path should not be in the document")
+	}
+	if op := doc.Paths["/sample/hello"]["post"]; op == nil || op.RequestBody == nil || op.OperationId != "openApiCtroller.Hello" {
+		t.Errorf("operation: %v", op)
+	}
+	if op := doc.Paths["/sample/"]["post"]; op == nil {
+		t.Error("can not find the index")
+	}
+	if op := doc.Paths["/user/{id}"]["get"]; op == nil || len(op.Parameters) != 1 || op.Parameters[0].In != "path" {
+		t.Errorf("operation: %v", op)
+	}
+	if doc.Components == nil {
+		t.Fatal("no components")
+	}
+	res := doc.Components.Schemas["openApiRes"]
+	if res == nil || len(res.Properties) != 3 || res.Properties["next"].Ref != "#/components/schemas/openApiRes" {
+		t.Errorf("schema: %v", res)
+	}
+	if res.Properties["items"].Items.Ref != "#/components/schemas/openApiReq" {
+		t.Errorf("schema: %v", res.Properties["items"])
+	}
+}
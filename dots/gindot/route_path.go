@@ -0,0 +1,118 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gindot
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//the key of the Engine in gin.Context
+const engineKey = "gindotEngine"
+
+//the route paths of the engine, the tree is rebuilt when a request does not match(at most once every second)
+//the requests read the tree without lock, only the rebuilding takes the mutex
+type routePaths struct {
+	tree      atomic.Value //map[string]*routeNode, key: method
+	refreshed int64        //atomic, unix nano of the last rebuilding
+	mutex     sync.Mutex   //for the rebuilding
+}
+
+//FullPath return the route path of the request, sample: "/v1/users/:id", it is empty if no route is matched
+//the request must be served by the Engine(gin 1.4 has no Context.FullPath)
+func FullPath(ctx *gin.Context) string {
+	e, ok := ctx.Value(engineKey).(*Engine)
+	if !ok {
+		return ""
+	}
+	return e.fullPath(ctx.Request.Method, ctx.Request.URL.Path)
+}
+
+func (c *Engine) fullPath(method string, path string) string {
+	p := &c.routePaths
+	tree, _ := p.tree.Load().(map[string]*routeNode)
+	if route := tree[method].match(path); len(route) > 0 {
+		return route
+	}
+	if time.Now().UnixNano()-atomic.LoadInt64(&p.refreshed) < int64(time.Second) {
+		return ""
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if now := time.Now().UnixNano(); now-atomic.LoadInt64(&p.refreshed) >= int64(time.Second) {
+		tree = make(map[string]*routeNode)
+		for _, it := range c.ginEngine.Routes() {
+			if tree[it.Method] == nil {
+				tree[it.Method] = &routeNode{}
+			}
+			tree[it.Method].add(it.Path)
+		}
+		p.tree.Store(tree)
+		atomic.StoreInt64(&p.refreshed, now)
+	} else {
+		tree, _ = p.tree.Load().(map[string]*routeNode)
+	}
+	return tree[method].match(path)
+}
+
+//one segment of the route paths(split by "/"), it is not changed after built
+type routeNode struct {
+	statics  map[string]*routeNode
+	param    *routeNode //":name"
+	catchAll string     //the route of "*name"
+	route    string     //the route that ends at the node
+}
+
+func (c *routeNode) add(route string) {
+	n := c
+	segments := strings.Split(route, "/")
+	for _, seg := range segments {
+		switch {
+		case strings.HasPrefix(seg, "*"):
+			n.catchAll = route
+			return
+		case strings.HasPrefix(seg, ":"):
+			if n.param == nil {
+				n.param = &routeNode{}
+			}
+			n = n.param
+		default:
+			if n.statics == nil {
+				n.statics = make(map[string]*routeNode)
+			}
+			if n.statics[seg] == nil {
+				n.statics[seg] = &routeNode{}
+			}
+			n = n.statics[seg]
+		}
+	}
+	n.route = route
+}
+
+//gin does not allow the conflict routes, so only one route matches the path
+func (c *routeNode) match(path string) string {
+	n := c
+	for n != nil {
+		seg := path
+		end := strings.IndexByte(path, '/')
+		if end >= 0 {
+			seg, path = path[:end], path[end+1:]
+		}
+		if next := n.statics[seg]; next != nil {
+			n = next
+		} else if n.param != nil && len(seg) > 0 {
+			n = n.param
+		} else {
+			return n.catchAll
+		}
+		if end < 0 {
+			return n.route
+		}
+	}
+	return ""
+}
@@ -4,10 +4,12 @@
 package gindot
 
 import (
+	"net/http"
 	"reflect"
 
 	"github.com/gin-gonic/gin"
 	"github.com/scryinfo/dot/dot"
+	"go.uber.org/zap"
 )
 
 const (
@@ -17,6 +19,11 @@ const (
 
 type configRouter struct {
 	RelativePath string `json:"relativePath"`
+	//live id of the gin engine, if it is empty, use the "Engine_" of relyLives(see TypeLiveRouterWith),
+	//or the default engine whose live id is EngineLiveId(see TypeLiveGinDot)
+	EngineLiveId dot.LiveId `json:"engineLiveId"`
+	//the names of the middlewares in order, they are used by all routes of the router, see RegisterMiddleware
+	Middlewares []string `json:"middlewares"`
 }
 
 //Router  gin router
@@ -25,6 +32,7 @@ type Router struct {
 	router  *gin.RouterGroup
 	config  configRouter
 	liveId  dot.LiveId
+	err     error //the error of AfterAllInject, the Start returns it
 }
 
 //construct dot
@@ -58,6 +66,22 @@ func TypeLiveRouter() []*dot.TypeLives {
 	}
 }
 
+//TypeLiveRouterWith generate data for structural  dot, the router(routerId) rely on the gin engine(engineId),
+//include gindot.Engine of engineId
+func TypeLiveRouterWith(routerId dot.LiveId, engineId dot.LiveId) []*dot.TypeLives {
+	return []*dot.TypeLives{&dot.TypeLives{
+		Meta: dot.Metadata{TypeId: RouterTypeId, NewDoter: func(conf interface{}) (dot.Dot, error) {
+			return newRouter(conf)
+		}},
+		Lives: []dot.Live{{
+			LiveId:    routerId,
+			RelyLives: map[string]dot.LiveId{"Engine_": engineId},
+		}},
+	},
+		TypeLiveGinDotWith(engineId),
+	}
+}
+
 //jayce edit
 //return config of Router
 func ConfigTypeLiveRouter() *dot.ConfigTypeLives {
@@ -71,14 +95,32 @@ func (c *Router) SetTypeId(tid dot.TypeId, lid dot.LiveId) {
 	c.liveId = lid
 }
 
+//AfterAllInject find the gin engine and make the router group, if there is no engine, the Start returns the error
 func (c *Router) AfterAllInject(l dot.Line) {
-	c.router = c.Engine_.GinEngine().Group(c.config.RelativePath)
+	if len(c.config.EngineLiveId) > 0 {
+		c.Engine_ = GetEngine(l, c.config.EngineLiveId)
+	}
+	if c.Engine_ == nil {
+		c.err = dot.SError.NotExisted.AddNewError("the gin engine of the router: " + c.liveId.String())
+		dot.Logger().Errorln("Router", zap.Error(c.err))
+		return
+	}
+	handlers, err := resolveMiddlewares(c.config.Middlewares)
+	if err != nil {
+		dot.Logger().Errorln("Router", zap.Error(err))
+	}
+	c.router = c.Engine_.GinEngine().Group(c.config.RelativePath, handlers...)
+	c.Engine_.addApiPrefix(c.config.RelativePath)
 }
 
-//Start start the gin
+//Start return the error if there is no gin engine, so the line does not start with the nil router
 func (c *Router) Start(ignore bool) error {
+	return c.err
+}
 
-	return nil
+//Engine return the gin dot of the router
+func (c *Router) Engine() *Engine {
+	return c.Engine_
 }
 
 func (c *Router) Router() *gin.RouterGroup {
@@ -92,17 +134,35 @@ func (c *Router) RelativePath() string {
 //all post
 func (c *Router) RouterPost(h interface{}, pre string) {
 	post := reflect.ValueOf(c.router).MethodByName("POST")
-	RouterSelf(h, pre, func(url string, gmethod reflect.Value) {
+	routerSelf(h, pre, func(url string, name string, gmethod reflect.Value) {
 		vs := []reflect.Value{reflect.ValueOf(url), gmethod}
 		post.Call(vs)
+		c.Engine_.addRoute(h, http.MethodPost, joinPaths(c.router.BasePath(), url), name)
 	})
 }
 
 //all get
 func (c *Router) RouterGet(h interface{}, pre string) {
 	get := reflect.ValueOf(c.router).MethodByName("GET")
-	RouterSelf(h, pre, func(url string, gmethod reflect.Value) {
+	routerSelf(h, pre, func(url string, name string, gmethod reflect.Value) {
 		vs := []reflect.Value{reflect.ValueOf(url), gmethod}
 		get.Call(vs)
+		c.Engine_.addRoute(h, http.MethodGet, joinPaths(c.router.BasePath(), url), name)
+	})
+}
+
+//all websocket
+func (c *Router) RouterWs(h interface{}, pre string) {
+	routerSelfType(h, pre, reflect.TypeOf(WsHandlerFunc(nil)), func(url string, name string, gmethod reflect.Value) {
+		c.router.GET(url, c.Engine_.WsHandler(gmethod.Interface().(func(*gin.Context, *WsConn))))
+		c.Engine_.addRoute(h, http.MethodGet, joinPaths(c.router.BasePath(), url), name)
+	})
+}
+
+//all server-sent events
+func (c *Router) RouterSse(h interface{}, pre string) {
+	routerSelfType(h, pre, reflect.TypeOf(SseHandlerFunc(nil)), func(url string, name string, gmethod reflect.Value) {
+		c.router.GET(url, c.Engine_.SseHandler(gmethod.Interface().(func(*gin.Context, *SseStream))))
+		c.Engine_.addRoute(h, http.MethodGet, joinPaths(c.router.BasePath(), url), name)
 	})
 }
@@ -0,0 +1,86 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gindot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scryinfo/dot/dot"
+	"github.com/scryinfo/dot/dots/line"
+)
+
+//the lives of the router type with the config, so the routers in one line have the different configs
+func testRouterLives(typeId dot.TypeId, conf string, lives ...dot.Live) *dot.TypeLives {
+	return &dot.TypeLives{
+		Meta: dot.Metadata{TypeId: typeId, NewDoter: func(args interface{}) (dot.Dot, error) {
+			return newRouter([]byte(conf))
+		}},
+		Lives: lives,
+	}
+}
+
+func TestRouter_Engines(t *testing.T) {
+	withRouter := TypeLiveRouterWith("r1", "e1")
+	withRouter[0].Meta.NewDoter = testRouterLives("", `{}`).Meta.NewDoter //no config file in the test
+	engines := withRouter[1]
+	engines.Lives = append(engines.Lives, TypeLiveGinDotWith("e2", EngineLiveId).Lives...)
+	engines.Meta.NewDoter = func(args interface{}) (dot.Dot, error) {
+		return newGinDot([]byte(`{"addr":"127.0.0.1:0"}`))
+	}
+
+	l, err := line.BuildAndStart(func(l dot.Line) error {
+		return l.PreAdd(withRouter[0], engines,
+			testRouterLives("testRouterE2", `{"engineLiveId":"e2","relativePath":"/e2"}`, dot.Live{LiveId: "r2"}),
+			testRouterLives("testRouterDefault", `{}`, dot.Live{LiveId: "r3"}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.ToLifer().Stop(true) //do not destroy, the logger of the line is used by the other tests
+
+	if e := GetEngine(l, "e2"); e == nil || e.LiveId() != "e2" {
+		t.Error("GetEngine", e)
+	}
+	if e := GetEngine(l, "none"); e != nil {
+		t.Error("GetEngine none", e.LiveId())
+	}
+
+	for rid, eid := range map[dot.LiveId]dot.LiveId{"r1": "e1", "r2": "e2", "r3": EngineLiveId} {
+		d, err := l.ToInjecter().GetByLiveId(rid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := d.(*Router)
+		if r.Engine() == nil || r.Engine().LiveId() != eid || r.Router() == nil {
+			t.Error(rid, r.Engine())
+		}
+	}
+
+	//the route is only on the engine of the router
+	d, _ := l.ToInjecter().GetByLiveId("r2")
+	d.(*Router).Router().GET("/hi", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "hi")
+	})
+	for eid, code := range map[dot.LiveId]int{"e1": http.StatusNotFound, "e2": http.StatusOK} {
+		rec := httptest.NewRecorder()
+		GetEngine(l, eid).GinEngine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/e2/hi", nil))
+		if rec.Code != code {
+			t.Error(eid, rec.Code)
+		}
+	}
+}
+
+func TestRouter_NoEngine(t *testing.T) {
+	r, err := newRouter([]byte(`{"engineLiveId":"none"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AfterAllInject(nil) //the default line, it has no engine
+	if err = r.Start(false); err == nil || r.Router() != nil {
+		t.Error("the router starts without the engine")
+	}
+}
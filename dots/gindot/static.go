@@ -0,0 +1,201 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gindot
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scryinfo/scryg/sutils/sfile"
+)
+
+type configStatic struct {
+	Prefix string `json:"prefix"` //url prefix, sample: "/ui", the default value is "/"
+	//the directory of the files, if it is not abs path, preferred to use the executable path
+	Dir string `json:"dir"`
+	//the name of the file system that is added by Engine.AddStaticFs, such as embedded files, it is prior to Dir
+	Fs           string   `json:"fs"`
+	Index        string   `json:"index"`        //the default value is "index.html"
+	Spa          bool     `json:"spa"`          //return the index for the unknown path, single page app
+	CacheControl string   `json:"cacheControl"` //Cache-Control header of the files(not index), sample: "public, max-age=86400"
+	Compressed   bool     `json:"compressed"`   //serve the precompressed file(name.br or name.gz) if the client accepts it
+	Excludes     []string `json:"excludes"`     //url prefixes that are not served, sample: ["/api"]. the prefixes of routers are excluded always
+}
+
+//AddStaticFs add the file system(such as embedded files) for the static config "fs"
+//it have to call before the gin engine start, sample: in AfterCreate event of the Engine
+func (c *Engine) AddStaticFs(name string, fs http.FileSystem) {
+	c.staticMutex.Lock()
+	if c.staticFs == nil {
+		c.staticFs = make(map[string]http.FileSystem)
+	}
+	c.staticFs[name] = fs
+	c.staticMutex.Unlock()
+}
+
+//the prefix of api router, the static files do not serve it
+func (c *Engine) addApiPrefix(prefix string) {
+	if len(prefix) < 1 || prefix == "/" {
+		return
+	}
+	if prefix[0] != '/' {
+		prefix = "/" + prefix
+	}
+	c.staticMutex.Lock()
+	c.apiPrefixes = append(c.apiPrefixes, prefix)
+	c.staticMutex.Unlock()
+}
+
+//the static files are served by the NoRoute of gin, so the api routes are prior
+func (c *Engine) serveStatic() {
+	if len(c.config.Statics) < 1 {
+		return
+	}
+	for i := range c.config.Statics {
+		s := &c.config.Statics[i]
+		s.Prefix = "/" + strings.Trim(s.Prefix, "/")
+		if len(s.Index) < 1 {
+			s.Index = "index.html"
+		}
+		if len(s.Dir) > 0 {
+			s.Dir = fullPath(s.Dir)
+		}
+	}
+
+	c.ginEngine.NoRoute(func(ctx *gin.Context) {
+		req := ctx.Request
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			return
+		}
+		p := req.URL.Path
+		if c.isApiPath(p) {
+			return
+		}
+		for i := range c.config.Statics {
+			s := &c.config.Statics[i]
+			if !strings.HasPrefix(p, s.Prefix) || (len(p) > len(s.Prefix) && s.Prefix != "/" && p[len(s.Prefix)] != '/') {
+				continue
+			}
+			excluded := false
+			for _, ex := range s.Excludes {
+				if strings.HasPrefix(p, ex) {
+					excluded = true
+					break
+				}
+			}
+			if excluded {
+				continue
+			}
+			if fs := c.staticFileSystem(s); fs != nil && serveStaticFile(ctx, fs, s, path.Clean("/"+strings.TrimPrefix(p, s.Prefix))) {
+				ctx.Abort()
+				return
+			}
+		}
+	})
+}
+
+func (c *Engine) isApiPath(p string) bool {
+	c.staticMutex.Lock()
+	defer c.staticMutex.Unlock()
+	for _, pre := range c.apiPrefixes {
+		if p == pre || strings.HasPrefix(p, strings.TrimSuffix(pre, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Engine) staticFileSystem(s *configStatic) http.FileSystem {
+	if len(s.Fs) > 0 {
+		c.staticMutex.Lock()
+		defer c.staticMutex.Unlock()
+		return c.staticFs[s.Fs]
+	}
+	if len(s.Dir) > 0 {
+		return http.Dir(s.Dir)
+	}
+	return nil
+}
+
+//return false if do not find the file
+func serveStaticFile(ctx *gin.Context, fs http.FileSystem, s *configStatic, name string) bool {
+	isIndex := false
+	if name == "/" || strings.HasSuffix(name, "/") {
+		name = path.Join(name, s.Index)
+		isIndex = true
+	} else if f, err := fs.Open(name); err == nil {
+		st, err := f.Stat()
+		_ = f.Close()
+		if err == nil && st.IsDir() {
+			name = path.Join(name, s.Index)
+			isIndex = true
+		}
+	}
+
+	if !sendStaticFile(ctx, fs, s, name, isIndex) {
+		if !s.Spa || isIndex || len(path.Ext(name)) > 0 { //the unknown file is not the page of the spa
+			return false
+		}
+		return sendStaticFile(ctx, fs, s, path.Join("/", s.Index), true)
+	}
+	return true
+}
+
+func sendStaticFile(ctx *gin.Context, fs http.FileSystem, s *configStatic, name string, isIndex bool) bool {
+	var f http.File
+	header := ctx.Writer.Header()
+	if s.Compressed {
+		accept := ctx.GetHeader("Accept-Encoding")
+		for _, enc := range [][2]string{{"br", ".br"}, {"gzip", ".gz"}} {
+			if !strings.Contains(accept, enc[0]) {
+				continue
+			}
+			if cf, err := fs.Open(name + enc[1]); err == nil {
+				f = cf
+				header.Set("Content-Encoding", enc[0])
+				break
+			}
+		}
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if f == nil {
+		var err error
+		if f, err = fs.Open(name); err != nil {
+			return false
+		}
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil || st.IsDir() {
+		header.Del("Content-Encoding")
+		return false
+	}
+	if isIndex {
+		header.Set("Cache-Control", "no-cache")
+	} else if len(s.CacheControl) > 0 {
+		header.Set("Cache-Control", s.CacheControl)
+	}
+	//the content type is detected by the name, not the compressed name
+	http.ServeContent(ctx.Writer, ctx.Request, name, st.ModTime(), f)
+	return true
+}
+
+//if it is not abs path, preferred to use the executable path
+func fullPath(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+	if ex, err := os.Executable(); err == nil {
+		t := filepath.Join(filepath.Dir(ex), file)
+		if sfile.ExistFile(t) {
+			return t
+		}
+	}
+	return file
+}
@@ -0,0 +1,82 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gindot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEngine_Static(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gindot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	_ = ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("index"), 0600)
+	_ = ioutil.WriteFile(filepath.Join(dir, "app.js"), []byte("js"), 0600)
+	_ = ioutil.WriteFile(filepath.Join(dir, "app.js.gz"), []byte("gz"), 0600)
+
+	e := &Engine{config: configEngine{Statics: []configStatic{{
+		Prefix:       "/ui",
+		Dir:          dir,
+		Spa:          true,
+		CacheControl: "max-age=60",
+		Compressed:   true,
+		Excludes:     []string{"/ui/raw"},
+	}}}}
+	if err = e.Create(nil); err != nil {
+		t.Fatal(err)
+	}
+	e.addApiPrefix("/ui/api")
+	e.GinEngine().GET("/ui/api/hello", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "hello")
+	})
+
+	get := func(url string, enc string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if len(enc) > 0 {
+			req.Header.Set("Accept-Encoding", enc)
+		}
+		e.GinEngine().ServeHTTP(w, req)
+		return w
+	}
+
+	cases := []struct {
+		url, enc, body string
+		code           int
+	}{
+		{"/ui/api/hello", "", "hello", http.StatusOK},
+		{"/ui/app.js", "", "js", http.StatusOK},
+		{"/ui/app.js", "gzip, br", "gz", http.StatusOK},
+		{"/ui/", "", "index", http.StatusOK},
+		{"/ui/user/list", "", "index", http.StatusOK}, //spa
+		{"/ui/none.js", "", "", http.StatusNotFound},
+		{"/ui/api/none", "", "", http.StatusNotFound},
+		{"/ui/raw/none", "", "", http.StatusNotFound},
+		{"/other", "", "", http.StatusNotFound},
+	}
+	for _, it := range cases {
+		w := get(it.url, it.enc)
+		if w.Code != it.code || (len(it.body) > 0 && w.Body.String() != it.body) {
+			t.Errorf("url: %s, code: %d, body: %s", it.url, w.Code, w.Body.String())
+		}
+	}
+
+	if w := get("/ui/app.js", "gzip"); w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Cache-Control") != "max-age=60" {
+		t.Errorf("header: %v", w.Header())
+	}
+	if w := get("/ui/", ""); w.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("header: %v", w.Header())
+	}
+}
@@ -0,0 +1,296 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gindot
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/scryinfo/dot/dot"
+	"go.uber.org/zap"
+)
+
+type configStream struct {
+	PingInterval   int      `json:"pingInterval"`   //second, send ping(websocket) or comment(sse) to the client, the default value is 30
+	IdleTimeout    int      `json:"idleTimeout"`    //second, close the websocket if do not receive any message(include pong), the default value is 60
+	WriteTimeout   int      `json:"writeTimeout"`   //second, the default value is 10
+	ReadLimit      int64    `json:"readLimit"`      //max size of the websocket message, 0 means no limit
+	AllowedOrigins []string `json:"allowedOrigins"` //origins of the websocket, ["*"] allows all, the default is same origin
+}
+
+//WsHandlerFunc the websocket handler of controller, the connection is closed after the function return
+//the handler have to read the messages(ReadMessage...), otherwise the pong can not be handled and the connection will be idle timeout
+//sample: func (c *SampleCtroller) Chat(ctx *gin.Context, conn *gindot.WsConn)
+type WsHandlerFunc func(ctx *gin.Context, conn *WsConn)
+
+//SseHandlerFunc the server-sent events handler of controller, the stream is ended after the function return
+//the handler should return when stream.Done() is closed
+//sample: func (c *SampleCtroller) Status(ctx *gin.Context, stream *gindot.SseStream)
+type SseHandlerFunc func(ctx *gin.Context, stream *SseStream)
+
+//WsConn websocket connection, it is tracked by the Engine and closed when the Engine stop
+type WsConn struct {
+	*websocket.Conn
+	writeTimeout time.Duration
+	writeMutex   sync.Mutex
+	done         chan struct{}
+	closeOnce    sync.Once
+}
+
+//WriteMessage it is safe for concurrent use
+func (c *WsConn) WriteMessage(messageType int, data []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	_ = c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	return c.Conn.WriteMessage(messageType, data)
+}
+
+//WriteJSON it is safe for concurrent use
+func (c *WsConn) WriteJSON(v interface{}) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	_ = c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	return c.Conn.WriteJSON(v)
+}
+
+//Done it is closed when the connection is closed
+func (c *WsConn) Done() <-chan struct{} {
+	return c.done
+}
+
+//Close send the close message and close the connection
+func (c *WsConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		_ = c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(c.writeTimeout))
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+//SseStream server-sent events stream, it is tracked by the Engine and closed when the Engine stop
+type SseStream struct {
+	ctx       *gin.Context
+	mutex     sync.Mutex
+	closed    bool
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+//Send send one event, the data is string or the value that will be json
+func (c *SseStream) Send(event string, id string, data interface{}) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return errors.New("the sse stream is closed")
+	}
+	if err := sse.Encode(c.ctx.Writer, sse.Event{Event: event, Id: id, Data: data}); err != nil {
+		return err
+	}
+	c.ctx.Writer.Flush()
+	return nil
+}
+
+//Done it is closed when the client is gone or the stream is closed
+func (c *SseStream) Done() <-chan struct{} {
+	return c.done
+}
+
+//Close end the stream
+func (c *SseStream) Close() error {
+	c.closeOnce.Do(func() {
+		c.mutex.Lock()
+		c.closed = true
+		c.mutex.Unlock()
+		close(c.done)
+	})
+	return nil
+}
+
+//keep the connection alive
+func (c *SseStream) ping() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.closed {
+		_, _ = c.ctx.Writer.WriteString(":ping\n\n")
+		c.ctx.Writer.Flush()
+	}
+}
+
+//all websocket
+func (c *Engine) RouterWs(h interface{}, pre string) {
+	routerSelfType(h, pre, reflect.TypeOf(WsHandlerFunc(nil)), func(url string, name string, gmethod reflect.Value) {
+		c.ginEngine.GET(url, c.WsHandler(gmethod.Interface().(func(*gin.Context, *WsConn))))
+		c.addRoute(h, http.MethodGet, url, name)
+	})
+}
+
+//all server-sent events
+func (c *Engine) RouterSse(h interface{}, pre string) {
+	routerSelfType(h, pre, reflect.TypeOf(SseHandlerFunc(nil)), func(url string, name string, gmethod reflect.Value) {
+		c.ginEngine.GET(url, c.SseHandler(gmethod.Interface().(func(*gin.Context, *SseStream))))
+		c.addRoute(h, http.MethodGet, url, name)
+	})
+}
+
+//WsHandler upgrade the connection and call the h
+func (c *Engine) WsHandler(h WsHandlerFunc) gin.HandlerFunc {
+	conf := &c.config.Stream
+	return func(ctx *gin.Context) {
+		logger := dot.Logger()
+		conn, err := c.upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+		if err != nil { //the upgrader write the http error
+			logger.Debugln("Engine", zap.Error(err))
+			return
+		}
+		ws := &WsConn{Conn: conn, writeTimeout: time.Duration(conf.WriteTimeout) * time.Second, done: make(chan struct{})}
+		if !c.trackStream(ws) {
+			_ = ws.Close()
+			return
+		}
+		defer func() {
+			c.untrackStream(ws)
+			_ = ws.Close()
+		}()
+
+		idle := time.Duration(conf.IdleTimeout) * time.Second
+		if conf.ReadLimit > 0 {
+			conn.SetReadLimit(conf.ReadLimit)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(idle))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(idle))
+		})
+		go func() {
+			ticker := time.NewTicker(time.Duration(conf.PingInterval) * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ws.done:
+					return
+				case <-ticker.C:
+					if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(ws.writeTimeout)); err != nil {
+						_ = ws.Close()
+						return
+					}
+				}
+			}
+		}()
+
+		h(ctx, ws)
+	}
+}
+
+//SseHandler make the server-sent events stream and call the h
+func (c *Engine) SseHandler(h SseHandlerFunc) gin.HandlerFunc {
+	conf := &c.config.Stream
+	return func(ctx *gin.Context) {
+		header := ctx.Writer.Header()
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Connection", "keep-alive")
+		header.Set("X-Accel-Buffering", "no") //for nginx
+		ctx.Status(http.StatusOK)
+		ctx.Writer.Flush()
+
+		stream := &SseStream{ctx: ctx, done: make(chan struct{})}
+		if !c.trackStream(stream) {
+			return
+		}
+		defer func() {
+			c.untrackStream(stream)
+			_ = stream.Close()
+		}()
+
+		go func() {
+			ticker := time.NewTicker(time.Duration(conf.PingInterval) * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stream.done:
+					return
+				case <-ctx.Request.Context().Done(): //the client is gone
+					_ = stream.Close()
+					return
+				case <-ticker.C:
+					stream.ping()
+				}
+			}
+		}()
+
+		h(ctx, stream)
+	}
+}
+
+func (c *Engine) initStream() {
+	conf := &c.config.Stream
+	if conf.PingInterval < 1 {
+		conf.PingInterval = 30
+	}
+	if conf.IdleTimeout < 1 {
+		conf.IdleTimeout = 60
+	}
+	if conf.WriteTimeout < 1 {
+		conf.WriteTimeout = 10
+	}
+	c.upgrader = websocket.Upgrader{}
+	if len(conf.AllowedOrigins) > 0 {
+		origins := make(map[string]bool, len(conf.AllowedOrigins))
+		for _, it := range conf.AllowedOrigins {
+			origins[it] = true
+		}
+		c.upgrader.CheckOrigin = func(r *http.Request) bool {
+			return origins["*"] || origins[r.Header.Get("Origin")]
+		}
+	}
+	c.streams = make(map[streamCloser]bool)
+}
+
+type streamCloser interface {
+	Close() error
+}
+
+//return false if the engine is stopping
+func (c *Engine) trackStream(s streamCloser) bool {
+	c.streamsMutex.Lock()
+	defer c.streamsMutex.Unlock()
+	if c.streams == nil {
+		return false
+	}
+	c.streams[s] = true
+	return true
+}
+
+func (c *Engine) untrackStream(s streamCloser) {
+	c.streamsMutex.Lock()
+	if c.streams != nil {
+		delete(c.streams, s)
+	}
+	c.streamsMutex.Unlock()
+}
+
+//StreamCount return the count of the open websocket and sse connections
+func (c *Engine) StreamCount() int {
+	c.streamsMutex.Lock()
+	defer c.streamsMutex.Unlock()
+	return len(c.streams)
+}
+
+//close all websocket and sse connections, do not accept the new one
+func (c *Engine) closeStreams() {
+	c.streamsMutex.Lock()
+	streams := c.streams
+	c.streams = nil
+	c.streamsMutex.Unlock()
+	for s := range streams {
+		_ = s.Close()
+	}
+}
@@ -0,0 +1,93 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gindot
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+type streamCtroller struct{}
+
+func (c *streamCtroller) Echo(ctx *gin.Context, conn *WsConn) {
+	for {
+		mt, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err = conn.WriteMessage(mt, data); err != nil {
+			return
+		}
+	}
+}
+
+func (c *streamCtroller) Status(ctx *gin.Context, stream *SseStream) {
+	_ = stream.Send("status", "1", "ok")
+	<-stream.Done()
+}
+
+func TestEngine_Stream(t *testing.T) {
+	e := &Engine{}
+	if err := e.Create(nil); err != nil {
+		t.Fatal(err)
+	}
+	e.RouterWs(&streamCtroller{}, "ws")
+	e.RouterSse(&streamCtroller{}, "sse")
+	server := httptest.NewServer(e.GinEngine())
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws/echo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err = conn.WriteMessage(websocket.TextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hi" {
+		t.Fatal(err, string(data))
+	}
+
+	res, err := http.Get(server.URL + "/sse/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Error(res.Header)
+	}
+	reader := bufio.NewReader(res.Body)
+	lines := make([]string, 0, 3)
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	if lines[0] != "id:1" || lines[1] != "event:status" || lines[2] != "data:ok" {
+		t.Error(lines)
+	}
+
+	for i := 0; i < 100 && e.StreamCount() != 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if e.StreamCount() != 2 {
+		t.Fatal(e.StreamCount())
+	}
+	_ = e.Stop(false)
+	if _, _, err = conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Error(err)
+	}
+	if e.StreamCount() != 0 {
+		t.Error(e.StreamCount())
+	}
+}
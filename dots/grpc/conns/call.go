@@ -0,0 +1,228 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package conns
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+//the retry and the hedging are the client interceptors, not the retryPolicy and hedgingPolicy of grpc service config,
+//because grpc(v1.27) enables the retryPolicy only by the environment variable GRPC_GO_RETRY=on, and does not support the hedgingPolicy
+
+//the same as the retryPolicy of grpc service config, only for unary call
+type retryConfig struct {
+	MaxAttempts       int      `json:"maxAttempts"`       //include the first call, 0 or 1 means disable
+	InitialBackoff    int      `json:"initialBackoff"`    //millisecond, the default value is 100
+	MaxBackoff        int      `json:"maxBackoff"`        //millisecond, the default value is 1000
+	BackoffMultiplier float64  `json:"backoffMultiplier"` //the default value is 2
+	RetryableCodes    []string `json:"retryableCodes"`    //sample: ["UNAVAILABLE"], the default value is ["UNAVAILABLE"]
+}
+
+//the same as the hedgingPolicy of grpc service config, only for unary call
+type hedgingConfig struct {
+	MaxAttempts   int      `json:"maxAttempts"`   //include the first call, 0 or 1 means disable
+	HedgingDelay  int      `json:"hedgingDelay"`  //millisecond, send the next call after the delay, if there is no response
+	NonFatalCodes []string `json:"nonFatalCodes"` //send the next call at once, if the code is one of them
+}
+
+type keepaliveConfig struct {
+	Time                int  `json:"time"`    //second, ping the server after the time of no activity, 0 means disable
+	Timeout             int  `json:"timeout"` //second, the default value is 20
+	PermitWithoutStream bool `json:"permitWithoutStream"`
+}
+
+func parseCodes(names []string) (map[codes.Code]bool, error) {
+	res := make(map[codes.Code]bool, len(names))
+	for _, it := range names {
+		var code codes.Code
+		if err := code.UnmarshalJSON([]byte(strconv.Quote(it))); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		res[code] = true
+	}
+	return res, nil
+}
+
+//return the dial options and the unary interceptors of the service
+func makeCallOptions(s *serviceConfig) ([]grpc.DialOption, []grpc.UnaryClientInterceptor, error) {
+	var opts []grpc.DialOption
+	var interceptors []grpc.UnaryClientInterceptor
+
+	if s.Retry.MaxAttempts > 1 && s.Hedging.MaxAttempts > 1 {
+		return nil, nil, errors.New("both retry and hedging are set, service: " + s.Name)
+	}
+
+	{
+		var callOpts []grpc.CallOption
+		if s.MaxRecvMsgSize > 0 {
+			callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(s.MaxRecvMsgSize))
+		}
+		if s.MaxSendMsgSize > 0 {
+			callOpts = append(callOpts, grpc.MaxCallSendMsgSize(s.MaxSendMsgSize))
+		}
+		if len(callOpts) > 0 {
+			opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+		}
+	}
+
+	if s.Keepalive.Time > 0 {
+		kp := keepalive.ClientParameters{
+			Time:                time.Duration(s.Keepalive.Time) * time.Second,
+			Timeout:             time.Duration(s.Keepalive.Timeout) * time.Second,
+			PermitWithoutStream: s.Keepalive.PermitWithoutStream,
+		}
+		if kp.Timeout <= 0 {
+			kp.Timeout = 20 * time.Second
+		}
+		opts = append(opts, grpc.WithKeepaliveParams(kp))
+	}
+
+	if s.Timeout > 0 { //the first one, the deadline include all retries
+		interceptors = append(interceptors, deadlineInterceptor(time.Duration(s.Timeout)*time.Millisecond))
+	}
+
+	if s.Retry.MaxAttempts > 1 {
+		names := s.Retry.RetryableCodes
+		if len(names) < 1 {
+			names = []string{"UNAVAILABLE"}
+		}
+		retryable, err := parseCodes(names)
+		if err != nil {
+			return nil, nil, err
+		}
+		interceptors = append(interceptors, retryInterceptor(&s.Retry, retryable))
+	}
+
+	if s.Hedging.MaxAttempts > 1 {
+		nonFatal, err := parseCodes(s.Hedging.NonFatalCodes)
+		if err != nil {
+			return nil, nil, err
+		}
+		interceptors = append(interceptors, hedgingInterceptor(&s.Hedging, nonFatal))
+	}
+
+	return opts, interceptors, nil
+}
+
+//set the default deadline, if the ctx has no deadline
+func deadlineInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func retryInterceptor(conf *retryConfig, retryable map[codes.Code]bool) grpc.UnaryClientInterceptor {
+	initial := time.Duration(conf.InitialBackoff) * time.Millisecond
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	max := time.Duration(conf.MaxBackoff) * time.Millisecond
+	if max <= 0 {
+		max = time.Second
+	}
+	multiplier := conf.BackoffMultiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		backoff := initial
+		var err error
+		for i := 0; i < conf.MaxAttempts; i++ {
+			if i > 0 {
+				//random backoff between 0 and backoff, the same as grpc
+				timer := time.NewTimer(time.Duration(rand.Int63n(int64(backoff)) + 1))
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return err
+				case <-timer.C:
+				}
+				if backoff = time.Duration(float64(backoff) * multiplier); backoff > max {
+					backoff = max
+				}
+			}
+			if err = invoker(ctx, method, req, reply, cc, opts...); err == nil || !retryable[status.Code(err)] {
+				return err
+			}
+		}
+		return err
+	}
+}
+
+//send the next call after the delay, return the first result that is ok or fatal
+//the reply of every call is new one, so the calls do not write the same reply
+func hedgingInterceptor(conf *hedgingConfig, nonFatal map[codes.Code]bool) grpc.UnaryClientInterceptor {
+	delay := time.Duration(conf.HedgingDelay) * time.Millisecond
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		replyMsg, ok := reply.(proto.Message)
+		if !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel() //cancel the other calls
+
+		type result struct {
+			reply proto.Message
+			err   error
+		}
+		results := make(chan result, conf.MaxAttempts)
+		call := func() {
+			r := proto.Clone(replyMsg)
+			r.Reset()
+			results <- result{reply: r, err: invoker(ctx, method, req, r, cc, opts...)}
+		}
+
+		sent, received := 1, 0
+		go call()
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		var err error
+		for received < sent {
+			select {
+			case <-timer.C:
+				if sent < conf.MaxAttempts {
+					sent++
+					go call()
+					timer.Reset(delay)
+				}
+			case res := <-results:
+				received++
+				if err = res.err; err == nil {
+					proto.Merge(replyMsg, res.reply)
+					return nil
+				}
+				if !nonFatal[status.Code(err)] {
+					return err
+				}
+				if sent < conf.MaxAttempts {
+					sent++
+					go call()
+					if !timer.Stop() {
+						select {
+						case <-timer.C:
+						default:
+						}
+					}
+					timer.Reset(delay)
+				}
+			}
+		}
+		return err
+	}
+}
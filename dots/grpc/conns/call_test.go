@@ -0,0 +1,147 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package conns
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/scryinfo/dot/dots/grpc/lb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+//the check func is called with the count of the calls, begin from 1
+type testHealthServer struct {
+	grpc_health_v1.HealthServer
+	count int32
+	check func(n int32) error
+	mutex sync.Mutex
+	md    metadata.MD //the incoming metadata of the last call
+}
+
+func (c *testHealthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	c.mutex.Lock()
+	c.md, _ = metadata.FromIncomingContext(ctx)
+	c.mutex.Unlock()
+	if err := c.check(atomic.AddInt32(&c.count, 1)); err != nil {
+		return nil, err
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func startTestServer(t *testing.T, check func(n int32) error) (*testHealthServer, string, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	h := &testHealthServer{check: check}
+	grpc_health_v1.RegisterHealthServer(s, h)
+	go func() { _ = s.Serve(lis) }()
+	return h, lis.Addr().String(), s.Stop
+}
+
+func callCheck(c *connsImp) error {
+	_, err := grpc_health_v1.NewHealthClient(c.ClientConn("hi")).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	return err
+}
+
+func newCallConns(t *testing.T, addr string, conf string) *connsImp {
+	d, err := newConns([]byte(`{"scheme":"call","services":[{"name":"hi","addrs":["` + addr + `"],` + conf + `}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := d.(*connsImp)
+	if err = c.Create(nil); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestConns_Retry(t *testing.T) {
+	h, addr, stop := startTestServer(t, func(n int32) error {
+		if n < 3 {
+			return status.Error(codes.Unavailable, "")
+		}
+		return nil
+	})
+	defer stop()
+	c := newCallConns(t, addr, `"retry":{"maxAttempts":3,"initialBackoff":10}`)
+	defer c.Stop(false)
+
+	if err := callCheck(c); err != nil || h.count != 3 {
+		t.Error(err, h.count)
+	}
+	if err := callCheck(c); err != nil || h.count != 4 {
+		t.Error(err, h.count)
+	}
+}
+
+func TestConns_Hedging(t *testing.T) {
+	h, addr, stop := startTestServer(t, func(n int32) error {
+		if n == 1 {
+			time.Sleep(time.Second)
+		}
+		return nil
+	})
+	defer stop()
+	c := newCallConns(t, addr, `"hedging":{"maxAttempts":2,"hedgingDelay":20}`)
+	defer c.Stop(false)
+
+	start := time.Now()
+	if err := callCheck(c); err != nil || time.Since(start) > 500*time.Millisecond {
+		t.Error(err, time.Since(start))
+	}
+	if n := atomic.LoadInt32(&h.count); n != 2 {
+		t.Error(n)
+	}
+
+	d, _ := newConns([]byte(`{"scheme":"call","services":[{"name":"hi","retry":{"maxAttempts":2},"hedging":{"maxAttempts":2}}]}`))
+	if err := d.(*connsImp).Create(nil); err == nil {
+		t.Error("both retry and hedging are set")
+	}
+}
+
+func TestConns_Timeout(t *testing.T) {
+	_, addr, stop := startTestServer(t, func(n int32) error {
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	})
+	defer stop()
+	c := newCallConns(t, addr, `"timeout":50`)
+	defer c.Stop(false)
+
+	if err := callCheck(c); status.Code(err) != codes.DeadlineExceeded {
+		t.Error(err)
+	}
+}
+
+func TestConns_Breaker(t *testing.T) {
+	_, addr, stop := startTestServer(t, func(n int32) error {
+		return status.Error(codes.Unavailable, "")
+	})
+	defer stop()
+	c := newCallConns(t, addr, `"breaker":{"failures":2}`)
+	defer c.Stop(false)
+
+	_ = callCheck(c)
+	_ = callCheck(c)
+	if s := c.BreakerStates("hi"); s[addr] != lb.BreakerOpen {
+		t.Fatal(s)
+	}
+	if err := callCheck(c); status.Code(err) != codes.Unavailable || status.Convert(err).Message() != "the circuit breakers of all addresses are open" {
+		t.Error(err)
+	}
+	if states := c.Check(nil).(map[string]map[string]string); states["hi"][addr] != lb.BreakerOpen {
+		t.Error(states)
+	}
+}
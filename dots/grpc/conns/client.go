@@ -0,0 +1,82 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package conns
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/scryinfo/dot/dot"
+	"google.golang.org/grpc"
+)
+
+const (
+	//ClientTagPrefix inject the typed client of the service, sample: `dot:"grpc:hi"`
+	//if the conns is not the default one(live id is ConnsTypeId), add the live id of it: `dot:"grpc:connsLiveId/hi"`
+	ClientTagPrefix = "grpc"
+)
+
+var (
+	clientNewers      = make(map[string][]reflect.Value) //key: service name
+	clientNewersMutex sync.RWMutex
+	clientConnType    = reflect.TypeOf((*grpc.ClientConn)(nil))
+)
+
+func init() {
+	dot.RegisterTagInjecter(ClientTagPrefix, injectClient)
+}
+
+//RegisterClient register the constructor of the typed client for the service, sample: RegisterClient("hi", hidot.NewHiDotClient)
+//then the field "Hi hidot.HiDotClient `dot:"grpc:hi"`" is injected with the client on the connection of the service,
+//the newer is func(*grpc.ClientConn) X or func(grpc.ClientConnInterface) X, one service can have the clients of different types
+//It must be called before the line inject the dots, so register it in init()
+func RegisterClient(serviceName string, newer interface{}) error {
+	v := reflect.ValueOf(newer)
+	if t := v.Type(); t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 1 || !clientConnType.AssignableTo(t.In(0)) {
+		return errors.Errorf("the client newer must be func(*grpc.ClientConn) X, but it is %T", newer)
+	}
+	clientNewersMutex.Lock()
+	defer clientNewersMutex.Unlock()
+	clientNewers[serviceName] = append(clientNewers[serviceName], v)
+	return nil
+}
+
+//return the registered newer of the service, whose result is assignable to the type
+func clientNewer(serviceName string, t reflect.Type) (reflect.Value, bool) {
+	clientNewersMutex.RLock()
+	defer clientNewersMutex.RUnlock()
+	for _, it := range clientNewers[serviceName] {
+		if it.Type().Out(0).AssignableTo(t) {
+			return it, true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+//the TagInjecter of ClientTagPrefix, the name is "serviceName" or "connsLiveId/serviceName"
+func injectClient(l dot.Line, t reflect.Type, name string) (interface{}, error) {
+	liveId := dot.LiveId(ConnsTypeId)
+	if index := strings.LastIndex(name, "/"); index >= 0 {
+		liveId, name = dot.LiveId(name[:index]), name[index+1:]
+	}
+	newer, ok := clientNewer(name, t)
+	if !ok {
+		return nil, dot.SError.NotExisted.AddNewError("grpc client: " + t.String() + " of the service " + name)
+	}
+	d, err := l.ToInjecter().GetByLiveId(liveId)
+	if err != nil {
+		return nil, err
+	}
+	conns, ok := d.(Conns)
+	if !ok {
+		return nil, dot.SError.DotInvalid.AddNewError("the dot is not the Conns: " + liveId.String())
+	}
+	conn := conns.ClientConn(name)
+	if conn == nil {
+		return nil, dot.SError.NotExisted.AddNewError("the connection of the service: " + name)
+	}
+	return newer.Call([]reflect.Value{reflect.ValueOf(conn)})[0].Interface(), nil
+}
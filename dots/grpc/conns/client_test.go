@@ -0,0 +1,56 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package conns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/scryinfo/dot/dot"
+	"github.com/scryinfo/dot/dots/line"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type testHealthUser struct {
+	Health grpc_health_v1.HealthClient `dot:"grpc:hi"`
+	Other  grpc_health_v1.HealthClient `dot:"grpc:other"`
+}
+
+func TestConns_InjectClient(t *testing.T) {
+	s, addr := startHealthServer(t, "one")
+	defer s.Stop()
+	if err := RegisterClient("hi", func(int) int { return 0 }); err == nil {
+		t.Error("no error for the invalid newer")
+	}
+	if err := RegisterClient("hi", grpc_health_v1.NewHealthClient); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &testHealthUser{}
+	l, err := line.BuildAndStart(func(l dot.Line) error {
+		return l.PreAdd(&dot.TypeLives{
+			Meta: dot.Metadata{TypeId: ConnsTypeId, NewDoter: func(conf interface{}) (dot.Dot, error) {
+				return newConns([]byte(`{"scheme":"inject","services":[{"name":"hi","addrs":["` + addr + `"]}]}`))
+			}},
+		}, &dot.TypeLives{
+			Meta: dot.Metadata{TypeId: "testHealthUser", NewDoter: func(conf interface{}) (dot.Dot, error) {
+				return user, nil
+			}},
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.ToLifer().Stop(true) //do not destroy, the logger of the line is used by the other tests
+
+	if user.Health == nil {
+		t.Fatal("the client is not injected")
+	}
+	if _, err = user.Health.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "one"}); err != nil {
+		t.Error(err)
+	}
+	if user.Other != nil {
+		t.Error("the client of the service without the newer is injected")
+	}
+}
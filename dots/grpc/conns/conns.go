@@ -5,18 +5,21 @@ package conns
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/scryinfo/dot/dot"
 	"github.com/scryinfo/dot/dots/grpc/lb"
 	"github.com/scryinfo/dot/dots/grpc/shared"
+	"github.com/scryinfo/dot/dots/sconfig"
+	"github.com/scryinfo/dot/dots/tlsdot"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/connectivity"
 	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -35,18 +38,52 @@ type Conns interface {
 	ServiceName() []string
 	//return schemeName,  Scheme is defined at https://github.com/grpc/grpc/blob/master/doc/naming.md
 	SchemeName() string
+	//Return the current addresses of the service
+	Addrs(serviceName string) []string
+	//Replace the addresses of the service, the live connections use the new addresses at once
+	UpdateAddrs(serviceName string, addrs []string) error
+	//Re-read the config file, and update the addresses of the services
+	ReloadConfig() error
+	//Return the circuit breaker states of the addresses of the service, key: address, value: lb.BreakerClosed, lb.BreakerOpen or lb.BreakerHalfOpen
+	//if the breaker is disabled, return nil
+	BreakerStates(serviceName string) map[string]string
+	//Return the connectivity state of the service, connectivity.Shutdown if the service is not existed
+	State(serviceName string) connectivity.State
+	//Return the connectivity states of the addresses of the service, key: address, the balance first do not support it(return empty)
+	AddrStates(serviceName string) map[string]connectivity.State
+	//Add the listener of the state changes of the services and the addresses
+	WatchState(listener StateListener)
+	//Return the latency of the methods that are called with the interceptor "timing", sorted by the method
+	Timings() []Timing
 }
 
+//StateListener the addr is empty if it is the state of the service(ClientConn), or it is the state of the address
+//do not block in the listener
+type StateListener func(serviceName string, addr string, state connectivity.State)
+
 type connsConfig struct {
 	Scheme   string          `json:"scheme"`
 	Services []serviceConfig `json:"services"`
 }
 
 type serviceConfig struct {
-	Name    string           `json:"name"`
-	Addrs   []string         `json:"addrs"`
-	Tls     shared.TlsConfig `json:"tls"`
-	Balance string           `json:"balance"` // round or first, the default value is round
+	Name      string             `json:"name"`
+	Addrs     []string           `json:"addrs"` // sample: "1.1.1.1:568", or "inproc://name" for the server in the same process
+	Tls       shared.TlsConfig   `json:"tls"`
+	Balance   string             `json:"balance"`   // round, first, weighted, least, p2c or hash, the default value is round
+	Weights   map[string]int     `json:"weights"`   // weights of the addresses for weighted, key: address, the default weight is 1
+	HashKey   string             `json:"hashKey"`   // the metadata key for hash, the default value is "lb-hash"
+	Discovery lb.DiscoveryConfig `json:"discovery"` // how to find the addresses, the default is static(use the Addrs)
+
+	Timeout        int              `json:"timeout"`        // millisecond, the default deadline of unary call, 0 means no deadline
+	Retry          retryConfig      `json:"retry"`          // retry the unary call, can not be set with hedging
+	Hedging        hedgingConfig    `json:"hedging"`        // send more unary calls if there is no response, can not be set with retry
+	Keepalive      keepaliveConfig  `json:"keepalive"`      // the keepalive of the connections
+	MaxRecvMsgSize int              `json:"maxRecvMsgSize"` // byte, 0 means the default of grpc
+	MaxSendMsgSize int              `json:"maxSendMsgSize"` // byte, 0 means the default of grpc
+	Breaker        lb.BreakerConfig `json:"breaker"`        // the circuit breaker of every address, do not support the balance first
+	Interceptors   []string         `json:"interceptors"`   // the names of the client interceptors in order, sample: ["log", "metadata"], see RegisterClientInterceptor
+	WaitReady      int              `json:"waitReady"`      // millisecond, the Start waits until the connection is ready, return error if timeout, 0 means do not wait
 }
 
 type ClientContext struct {
@@ -56,8 +93,21 @@ type ClientContext struct {
 }
 
 type connsImp struct {
-	conns  map[string]*ClientContext
-	config connsConfig
+	conns       map[string]*ClientContext
+	config      connsConfig
+	builder     *lb.ClientBuilder
+	discoveries map[string]lb.Discovery
+	breakers    map[string]*lb.Breakers
+	states      map[string]*lb.AddrStates
+	timings     *timings
+	Tls         *tlsdot.Tls `dot:""` //the tls of the line, see tlsdot.Get
+	listeners   []StateListener
+	mutex       sync.Mutex     //for the listeners
+	connsMutex  sync.RWMutex   //for the conns and the addresses of the config.Services, they are changed by Stop and HotConfig
+	line        dot.Line       //reload the config of the line
+	watchers    sync.WaitGroup //the state watchers
+	typeId      dot.TypeId
+	liveId      dot.LiveId
 }
 
 //Construction component
@@ -76,7 +126,8 @@ func newConns(conf interface{}) (dot.Dot, error) {
 	}
 
 	d := &connsImp{
-		config: *dconf,
+		config:  *dconf,
+		timings: newTimings(),
 	}
 
 	return d, err
@@ -107,7 +158,16 @@ func ConnsConfigTypeLives() *dot.ConfigTypeLives {
 	}
 }
 
+func (c *connsImp) SetTypeId(tid dot.TypeId, lid dot.LiveId) {
+	c.typeId = tid
+	c.liveId = lid
+}
+
 func (c *connsImp) Create(l dot.Line) error {
+	c.line = l
+	if c.Tls == nil {
+		c.Tls = tlsdot.Get(l)
+	}
 	logger := dot.Logger()
 	var err error = nil
 	sa := make(map[string][]string, len(c.config.Services))
@@ -117,7 +177,22 @@ func (c *connsImp) Create(l dot.Line) error {
 			sa[s.Name] = s.Addrs
 		}
 	}
-	resolver.Register(lb.NewClientBuilder(c.config.Scheme, sa))
+	c.builder = lb.NewClientBuilder(c.config.Scheme, sa) //only for the ClientConns of this conns, do not register it in grpc
+	c.breakers = make(map[string]*lb.Breakers, len(c.config.Services))
+	c.states = make(map[string]*lb.AddrStates, len(c.config.Services))
+	for i := range c.config.Services {
+		s := &c.config.Services[i]
+		breakers := lb.NewBreakers(s.Breaker)
+		if breakers != nil {
+			c.breakers[s.Name] = breakers
+		}
+		name := s.Name
+		states := lb.NewAddrStates(func(addr string, state connectivity.State) {
+			c.publishState(name, addr, state)
+		})
+		c.states[s.Name] = states
+		c.builder.SetBalanceOptions(s.Name, lb.BalanceOptions{Weights: s.Weights, HashKey: s.HashKey, Breakers: breakers, States: states})
+	}
 	c.conns = make(map[string]*ClientContext, len(c.config.Services))
 
 	errDo := func(er error) {
@@ -127,83 +202,65 @@ func (c *connsImp) Create(l dot.Line) error {
 		err = er
 	}
 
+	c.discoveries = make(map[string]lb.Discovery, len(c.config.Services))
+	for i := range c.config.Services {
+		s := &c.config.Services[i]
+		d, e1 := lb.NewDiscovery(s.Name, s.Discovery, s.Addrs)
+		if e1 != nil {
+			errDo(e1)
+			continue
+		}
+		name := s.Name
+		d.Start(func(addrs []string) {
+			logger.Infoln("connsImp", zap.String("service", name), zap.Strings("addrs", addrs))
+			c.builder.UpdateAddrs(name, addrs)
+		})
+		c.discoveries[name] = d
+	}
+
 ForServices:
 	for i := range c.config.Services {
-		var e1 error = nil
 		s := &c.config.Services[i]
 		target := fmt.Sprintf("%s:///%s", c.config.Scheme, s.Name)
 
+		callOpts, interceptors, e1 := makeCallOptions(s)
+		if e1 != nil {
+			errDo(e1)
+			continue ForServices
+		}
+		unary, stream, e1 := makeInterceptors(s.Interceptors, c.timings)
+		if e1 != nil {
+			errDo(e1)
+			continue ForServices
+		}
+		if interceptors = append(interceptors, unary...); len(interceptors) > 0 { //the configured interceptors are called for every retry
+			callOpts = append(callOpts, grpc.WithChainUnaryInterceptor(interceptors...))
+		}
+		if len(stream) > 0 {
+			callOpts = append(callOpts, grpc.WithChainStreamInterceptor(stream...))
+		}
+		//the addresses may be changed by the discovery or UpdateAddrs, so always use the dialer of tcp and inproc
+		callOpts = append(callOpts, grpc.WithContextDialer(shared.Dial))
+
 		var rpc ClientContext
 		funRpc := func(rpc *ClientContext, target string, opts ...grpc.DialOption) error {
 			rpc.Ctx, rpc.Cancel = context.WithCancel(context.Background())
 			var e error
+			opts = append(opts, grpc.WithResolvers(c.builder))
+			opts = append(opts, callOpts...)
 			rpc.ClientConn, e = grpc.DialContext(rpc.Ctx, target, opts...)
 			return e
 		}
 		{
-			switch {
-			case len(s.Tls.CaPem) > 0 && len(s.Tls.Key) > 0 && len(s.Tls.Pem) > 0: //both tls
-				caPemFile := shared.GetFullPathFile(s.Tls.CaPem)
-				if len(caPemFile) < 1 {
-					errDo(errors.New("the caPem is not empty, and can not find the file: " + s.Tls.CaPem))
-					continue ForServices
-				}
-				keyFile := shared.GetFullPathFile(s.Tls.Key)
-				if len(keyFile) < 1 {
-					errDo(errors.New("the Key is not empty, and can not find the file: " + s.Tls.Key))
-					continue ForServices
-				}
-
-				pemFile := shared.GetFullPathFile(s.Tls.Pem)
-				if len(pemFile) < 1 {
-					errDo(errors.New("the Pem is not empty, and can not find the file: " + s.Tls.Pem))
-					continue ForServices
-				}
-
-				var tc credentials.TransportCredentials
-				{
-					pool := x509.NewCertPool()
-					{
-						caCrt, err1 := ioutil.ReadFile(caPemFile)
-						if err1 != nil {
-							errDo(errors.WithStack(err1))
-							continue ForServices
-						}
-						if !pool.AppendCertsFromPEM(caCrt) {
-							errDo(errors.New("credentials: failed to append certificates"))
-							continue ForServices
-						}
-					}
-					cert, err1 := tls.LoadX509KeyPair(pemFile, keyFile)
-					if err1 != nil {
-						errDo(errors.WithStack(err1))
-						continue ForServices
-					}
-
-					tc = credentials.NewTLS(&tls.Config{
-						ServerName:   s.Tls.ServerNameOverride,
-						Certificates: []tls.Certificate{cert},
-						RootCAs:      pool, //ClientConn, use the RootCAs
-					})
-				}
-
-				logger.Infoln("connsImp", zap.String("", "tls with ca"))
-				e1 = funRpc(&rpc, target, lb.Balance(s.Balance), grpc.WithTransportCredentials(tc))
-			case len(s.Tls.Pem) > 0: //just server
-				pemfile := shared.GetFullPathFile(s.Tls.Pem)
-				if len(pemfile) < 1 {
-					errDo(errors.New("the Pem is not empty, and can not find the file: " + s.Tls.Pem))
-					continue ForServices
-				}
-
-				creds, err1 := credentials.NewClientTLSFromFile(pemfile, s.Tls.ServerNameOverride)
-				if err1 != nil {
-					errDo(errors.WithStack(err1))
-					continue ForServices
-				}
-				logger.Infoln("connsImp", zap.String("", "tls no ca"))
+			creds, err1 := c.Tls.ClientCredentials(s.Tls)
+			if err1 != nil {
+				errDo(err1)
+				continue ForServices
+			}
+			if creds != nil {
+				logger.Infoln("connsImp", zap.String("", "tls"), zap.Bool("ca", len(s.Tls.CaPem) > 0))
 				e1 = funRpc(&rpc, target, lb.Balance(s.Balance), grpc.WithTransportCredentials(creds))
-			default: //no tls
+			} else {
 				logger.Infoln("connsImp", zap.String("", "no tls"))
 				e1 = funRpc(&rpc, target, lb.Balance(s.Balance), grpc.WithInsecure())
 			}
@@ -211,20 +268,80 @@ ForServices:
 		if e1 != nil {
 			errDo(errors.WithStack(e1))
 		} else {
+			c.connsMutex.Lock()
 			c.conns[s.Name] = &rpc
+			c.connsMutex.Unlock()
+			c.watchers.Add(1)
+			go c.watchState(s.Name, &rpc)
+		}
+	}
+	return err
+}
+
+//Start wait until the connections are ready, if the waitReady of the service is set
+func (c *connsImp) Start(ignore bool) error {
+	var err error = nil
+	for i := range c.config.Services {
+		s := &c.config.Services[i]
+		rpc, ok := c.conn(s.Name)
+		if s.WaitReady < 1 || !ok {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(rpc.Ctx, time.Duration(s.WaitReady)*time.Millisecond)
+		state := rpc.ClientConn.GetState()
+		for state != connectivity.Ready && rpc.ClientConn.WaitForStateChange(ctx, state) {
+			state = rpc.ClientConn.GetState()
+		}
+		cancel()
+		if state != connectivity.Ready {
+			e1 := errors.Errorf("the connection of the service is not ready, service: %s, state: %s, wait: %dms", s.Name, state, s.WaitReady)
+			if err != nil {
+				dot.Logger().Errorln("connsImp", zap.Error(err))
+			}
+			err = e1
 		}
 	}
 	return err
 }
 
+//watch the state of the service until the connection is closed
+func (c *connsImp) watchState(serviceName string, rpc *ClientContext) {
+	defer c.watchers.Done()
+	state := rpc.ClientConn.GetState()
+	for rpc.ClientConn.WaitForStateChange(rpc.Ctx, state) {
+		state = rpc.ClientConn.GetState()
+		c.publishState(serviceName, "", state)
+		if state == connectivity.Shutdown {
+			return
+		}
+	}
+}
+
+func (c *connsImp) publishState(serviceName string, addr string, state connectivity.State) {
+	dot.Logger().Infoln("connsImp", zap.String("service", serviceName), zap.String("addr", addr), zap.String("state", state.String()))
+	c.mutex.Lock()
+	listeners := c.listeners
+	c.mutex.Unlock()
+	for _, it := range listeners {
+		it(serviceName, addr, state)
+	}
+}
+
 func (c *connsImp) Stop(ignore bool) error {
 	var err error = nil
-	if len(c.conns) > 0 {
-		conns := c.conns
-		c.conns = nil
+	for _, d := range c.discoveries {
+		d.Stop()
+	}
+	c.discoveries = nil
+	c.connsMutex.Lock()
+	conns := c.conns
+	c.conns = nil
+	c.connsMutex.Unlock()
+	if len(conns) > 0 {
 		for _, conn := range conns {
 			if conn.ClientConn != nil {
 				e1 := conn.ClientConn.Close() //todo Cancel request?
+				conn.Cancel()                 //stop the state watcher
 				if e1 != nil {                //do not return , close all connection
 					if err != nil { //log the err
 						dot.Logger().Errorln(err.Error())
@@ -235,13 +352,21 @@ func (c *connsImp) Stop(ignore bool) error {
 		}
 	}
 
-	//todo This function is for testing only,
-	resolver.UnregisterForTesting(c.config.Scheme)
-
+	c.watchers.Wait() //the watchers log the shutdown, wait for them before the logger is destroyed
 	return err
 }
 
+//return the connection of the service
+func (c *connsImp) conn(serviceName string) (*ClientContext, bool) {
+	c.connsMutex.RLock()
+	defer c.connsMutex.RUnlock()
+	rpc, ok := c.conns[serviceName]
+	return rpc, ok
+}
+
 func (c *connsImp) DefaultClientConn() *grpc.ClientConn {
+	c.connsMutex.RLock()
+	defer c.connsMutex.RUnlock()
 	var conn *grpc.ClientConn = nil
 	if len(c.conns) == 1 {
 		for k := range c.conns {
@@ -254,20 +379,16 @@ func (c *connsImp) DefaultClientConn() *grpc.ClientConn {
 
 func (c *connsImp) ClientConn(serviceName string) *grpc.ClientConn {
 	var conn *grpc.ClientConn = nil
-	if len(c.conns) > 0 {
-		if c, ok := c.conns[serviceName]; ok {
-			conn = c.ClientConn
-		}
+	if rpc, ok := c.conn(serviceName); ok {
+		conn = rpc.ClientConn
 	}
 	return conn
 }
 
 func (c *connsImp) ClientContext(serviceName string) *ClientContext {
 	var conn *ClientContext = nil
-	if len(c.conns) > 0 {
-		if c, ok := c.conns[serviceName]; ok {
-			conn = c
-		}
+	if rpc, ok := c.conn(serviceName); ok {
+		conn = rpc
 	}
 	return conn
 }
@@ -286,3 +407,123 @@ func (c *connsImp) ServiceName() []string {
 func (c *connsImp) SchemeName() string {
 	return c.config.Scheme
 }
+
+func (c *connsImp) Addrs(serviceName string) []string {
+	if c.builder == nil {
+		return nil
+	}
+	return c.builder.Addrs(serviceName)
+}
+
+func (c *connsImp) UpdateAddrs(serviceName string, addrs []string) error {
+	if _, ok := c.conn(serviceName); !ok || c.builder == nil {
+		return dot.SError.NotExisted.AddNewError(serviceName)
+	}
+	dot.Logger().Infoln("connsImp", zap.String("service", serviceName), zap.Strings("addrs", addrs))
+	c.builder.UpdateAddrs(serviceName, addrs)
+	return nil
+}
+
+func (c *connsImp) BreakerStates(serviceName string) map[string]string {
+	if b := c.breakers[serviceName]; b != nil {
+		return b.States()
+	}
+	return nil
+}
+
+func (c *connsImp) State(serviceName string) connectivity.State {
+	if rpc, ok := c.conn(serviceName); ok {
+		return rpc.ClientConn.GetState()
+	}
+	return connectivity.Shutdown
+}
+
+func (c *connsImp) AddrStates(serviceName string) map[string]connectivity.State {
+	if s := c.states[serviceName]; s != nil {
+		return s.States()
+	}
+	return nil
+}
+
+func (c *connsImp) WatchState(listener StateListener) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.listeners = append(append([]StateListener(nil), c.listeners...), listener) //copy on write, publish without the lock
+}
+
+func (c *connsImp) Timings() []Timing {
+	return c.timings.list()
+}
+
+//Check return the circuit breaker states of all the services, key: service name
+func (c *connsImp) Check(args interface{}) interface{} {
+	res := make(map[string]map[string]string, len(c.breakers))
+	for name, b := range c.breakers {
+		res[name] = b.States()
+	}
+	return res
+}
+
+//ReloadConfig re-read the config file of the line(see dot.SConfig), the line may use the custom path and file
+func (c *connsImp) ReloadConfig() error {
+	if c.line == nil || c.line.SConfig() == nil {
+		return dot.SError.Config.AddNewError("no config of the line, conns: " + c.liveId.String())
+	}
+	sc := c.line.SConfig()
+	if len(sc.ConfigFile()) < 1 {
+		return dot.SError.Config.AddNewError("no config file of the line, conns: " + c.liveId.String())
+	}
+	data, err := ioutil.ReadFile(filepath.Join(sc.ConfigPath(), sc.ConfigFile()))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	conf := sconfig.NewConfiger()
+	if err = conf.Marshal(data); err != nil {
+		return errors.WithStack(err)
+	}
+	if !c.HotConfig(conf) {
+		return dot.SError.Config.AddNewError("can not reload the conns config: " + c.liveId.String())
+	}
+	return nil
+}
+
+//HotConfig update the addresses of the static discovery services, the other config items do not change
+func (c *connsImp) HotConfig(newConf dot.SConfig) bool {
+	logger := dot.Logger()
+	config := &dot.Config{}
+	if err := newConf.Unmarshal(config); err != nil {
+		logger.Errorln("connsImp", zap.Error(err))
+		return false
+	}
+	bs, err := dot.MarshalConfig(config.FindConfig(c.typeId, c.liveId))
+	if err != nil || bs == nil {
+		logger.Errorln("connsImp", zap.String("", "can not find the config: "+c.liveId.String()), zap.Error(err))
+		return false
+	}
+	dconf := &connsConfig{}
+	if err = dot.UnMarshalConfig(bs, dconf); err != nil {
+		logger.Errorln("connsImp", zap.Error(err))
+		return false
+	}
+
+	res := true
+	for i := range dconf.Services {
+		s := &dconf.Services[i]
+		if len(s.Discovery.Type) > 0 && !strings.EqualFold(s.Discovery.Type, lb.DiscoveryStatic) { //the addresses come from the discovery
+			continue
+		}
+		if err = c.UpdateAddrs(s.Name, s.Addrs); err != nil { //can not add new service
+			logger.Errorln("connsImp", zap.Error(err))
+			res = false
+			continue
+		}
+		c.connsMutex.Lock()
+		for j := range c.config.Services {
+			if c.config.Services[j].Name == s.Name {
+				c.config.Services[j].Addrs = s.Addrs
+			}
+		}
+		c.connsMutex.Unlock()
+	}
+	return res
+}
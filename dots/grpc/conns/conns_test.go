@@ -0,0 +1,265 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package conns
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scryinfo/dot/dot"
+	"github.com/scryinfo/dot/dots/grpc/shared"
+	"github.com/scryinfo/dot/dots/sconfig"
+	"github.com/scryinfo/dot/dots/tlsdot"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func startHealthServer(t *testing.T, serving string) (*grpc.Server, string) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	h := health.NewServer()
+	h.SetServingStatus(serving, grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, h)
+	go func() { _ = s.Serve(lis) }()
+	return s, lis.Addr().String()
+}
+
+func newTestConns(t *testing.T, addr string) *connsImp {
+	d, err := newConns([]byte(`{"scheme":"same","services":[{"name":"hi","addrs":["` + addr + `"]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := d.(*connsImp)
+	if err = c.Create(nil); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestConns_SameScheme(t *testing.T) {
+	s1, addr1 := startHealthServer(t, "one")
+	defer s1.Stop()
+	s2, addr2 := startHealthServer(t, "two")
+	defer s2.Stop()
+
+	c1 := newTestConns(t, addr1)
+	defer c1.Stop(false)
+	c2 := newTestConns(t, addr2)
+	defer c2.Stop(false)
+
+	check := func(c *connsImp, service string, code codes.Code) {
+		_, err := grpc_health_v1.NewHealthClient(c.ClientConn("hi")).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+		if status.Code(err) != code {
+			t.Error(service, err)
+		}
+	}
+	check(c1, "one", codes.OK)
+	check(c1, "two", codes.NotFound)
+	check(c2, "two", codes.OK)
+	check(c2, "one", codes.NotFound)
+
+	c1.Stop(false) //the other conns is not affected
+	check(c2, "two", codes.OK)
+}
+
+func TestConns_Inproc(t *testing.T) {
+	lis, err := shared.Listen("inproc://conns-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = shared.Listen("inproc://conns-test"); err == nil {
+		t.Error("the inproc address is listened twice")
+	}
+	s := grpc.NewServer()
+	h := health.NewServer()
+	h.SetServingStatus("one", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, h)
+	go func() { _ = s.Serve(lis) }()
+
+	c := newTestConns(t, "inproc://conns-test")
+	defer c.Stop(false)
+	if _, err = grpc_health_v1.NewHealthClient(c.ClientConn("hi")).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "one"}); err != nil {
+		t.Error(err)
+	}
+
+	//the inproc address from UpdateAddrs
+	c2 := newTestConns(t, "127.0.0.1:1")
+	defer c2.Stop(false)
+	if err = c2.UpdateAddrs("hi", []string{"inproc://conns-test"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err = grpc_health_v1.NewHealthClient(c2.ClientConn("hi")).Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "one"}, grpc.WaitForReady(true)); err != nil {
+		t.Error(err)
+	}
+
+	s.Stop() //close the listener, the name can be listened again
+	if lis, err = shared.Listen("inproc://conns-test"); err != nil {
+		t.Error(err)
+	} else {
+		_ = lis.Close()
+	}
+	if _, err = shared.Dial(context.Background(), "inproc://none"); err == nil {
+		t.Error("dial the inproc address without listener")
+	}
+}
+
+func TestConns_State(t *testing.T) {
+	s, addr := startHealthServer(t, "one")
+	defer s.Stop()
+	lis, err := net.Listen("tcp", "127.0.0.1:0") //no server
+	if err != nil {
+		t.Fatal(err)
+	}
+	none := lis.Addr().String()
+	_ = lis.Close()
+
+	d, err := newConns([]byte(`{"scheme":"state","services":[{"name":"hi","addrs":["` + addr + `"],"waitReady":5000},{"name":"none","addrs":["` + none + `"]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := d.(*connsImp)
+	events := make(chan string, 100)
+	c.WatchState(func(serviceName string, addr string, state connectivity.State) {
+		select {
+		case events <- serviceName + " " + addr + " " + state.String():
+		default:
+		}
+	})
+	if err = c.Create(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Start(false); err != nil {
+		t.Fatal(err)
+	}
+	if c.State("hi") != connectivity.Ready || c.AddrStates("hi")[addr] != connectivity.Ready {
+		t.Error(c.State("hi"), c.AddrStates("hi"))
+	}
+	if c.State("unknown") != connectivity.Shutdown || c.AddrStates("unknown") != nil {
+		t.Error("the unknown service")
+	}
+	wants := map[string]bool{"hi  READY": true, "hi " + addr + " READY": true}
+	for len(wants) > 0 {
+		select {
+		case got := <-events:
+			delete(wants, got)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event", wants)
+		}
+	}
+
+	c.config.Services[1].WaitReady = 100 //the none service can not be ready
+	if err = c.Start(false); err == nil {
+		t.Error("the none service is ready")
+	}
+	_ = c.Stop(false)
+}
+
+func TestConns_HotConfig(t *testing.T) {
+	d, err := newConns([]byte(`{"scheme":"hot","services":[{"name":"hi","addrs":["127.0.0.1:1"],"discovery":{"type":"Static"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := d.(*connsImp)
+	c.SetTypeId(ConnsTypeId, ConnsTypeId)
+	if err = c.Create(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop(false)
+
+	conf := sconfig.NewConfiger()
+	if err = conf.Marshal([]byte(`{"dots":[{"metaData":{"typeId":"` + ConnsTypeId + `"},"lives":[{"liveId":"` + ConnsTypeId + `",
+		"json":{"services":[{"name":"hi","addrs":["127.0.0.1:2"],"discovery":{"type":"Static"}}]}}]}]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if !c.HotConfig(conf) {
+		t.Fatal("hot config")
+	}
+	if addrs := c.Addrs("hi"); len(addrs) != 1 || addrs[0] != "127.0.0.1:2" {
+		t.Error(addrs)
+	}
+}
+
+//the line with the config path and file
+type testConfigLine struct {
+	dot.Line
+	sconfig dot.SConfig
+}
+
+func (c *testConfigLine) SConfig() dot.SConfig { return c.sconfig }
+
+type testSConfig struct {
+	dot.SConfig
+	path, file string
+}
+
+func (c *testSConfig) ConfigPath() string { return c.path }
+func (c *testSConfig) ConfigFile() string { return c.file }
+
+func TestConns_ReloadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(addr string) {
+		data := `{"dots":[{"metaData":{"typeId":"` + ConnsTypeId + `"},"lives":[{"liveId":"reload","json":{"services":[{"name":"hi","addrs":["` + addr + `"]}]}}]}]}`
+		if err := ioutil.WriteFile(filepath.Join(dir, "custom.json"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := newConns([]byte(`{"scheme":"reload","services":[{"name":"hi","addrs":["127.0.0.1:1"]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := d.(*connsImp)
+	c.SetTypeId(ConnsTypeId, "reload")
+	c.Tls = tlsdot.New() //the test line has no injecter
+	if err = c.Create(&testConfigLine{sconfig: &testSConfig{path: dir, file: "custom.json"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop(false)
+
+	done := make(chan struct{})
+	go func() { //use the conns at the same time
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = c.State("hi")
+			_ = c.ClientConn("hi")
+			_ = c.UpdateAddrs("hi", []string{"127.0.0.1:3"})
+		}
+	}()
+	for _, addr := range []string{"127.0.0.1:2", "127.0.0.1:4"} {
+		write(addr)
+		if err = c.ReloadConfig(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+	if err = c.ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if addrs := c.Addrs("hi"); len(addrs) != 1 || addrs[0] != "127.0.0.1:4" {
+		t.Error(addrs)
+	}
+
+	c.line = &testConfigLine{sconfig: &testSConfig{path: dir, file: "none.json"}}
+	if err = c.ReloadConfig(); err == nil {
+		t.Error("reload the file that is not existed")
+	}
+}
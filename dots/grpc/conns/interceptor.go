@@ -0,0 +1,254 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package conns
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scryinfo/dot/dot"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	//the names of the built-in client interceptors
+	InterceptorLog         = "log"         //log every call
+	InterceptorMetadata    = "metadata"    //propagate the request id from the incoming call or the context
+	InterceptorForwardAuth = "forwardAuth" //propagate the request id and the auth token of the incoming call, use it only for the trusted services
+	InterceptorTiming      = "timing"      //count the latency of every method of the conns, see Conns.Timings
+
+	//AuthorizationKey the metadata key of auth token
+	AuthorizationKey = "authorization"
+	//RequestIdKey the metadata key of request id
+	RequestIdKey = "x-request-id"
+)
+
+//ClientInterceptor the named client interceptor, Unary or Stream can be nil
+type ClientInterceptor struct {
+	Unary  grpc.UnaryClientInterceptor
+	Stream grpc.StreamClientInterceptor
+}
+
+var (
+	clientInterceptors      = make(map[string]ClientInterceptor)
+	clientInterceptorsMutex sync.RWMutex
+)
+
+//RegisterClientInterceptor the dot register the interceptor, and then the services use it by the name in config "interceptors"
+//It must be called before the conns is created, so the dot should be the relyLives of the conns, or register it in init()
+func RegisterClientInterceptor(name string, interceptor ClientInterceptor) {
+	clientInterceptorsMutex.Lock()
+	defer clientInterceptorsMutex.Unlock()
+	clientInterceptors[name] = interceptor
+}
+
+//GetClientInterceptor return the registered interceptor
+func GetClientInterceptor(name string) (ClientInterceptor, bool) {
+	clientInterceptorsMutex.RLock()
+	defer clientInterceptorsMutex.RUnlock()
+	it, ok := clientInterceptors[name]
+	return it, ok
+}
+
+func init() {
+	RegisterClientInterceptor(InterceptorLog, ClientInterceptor{Unary: logUnaryInterceptor, Stream: logStreamInterceptor})
+	RegisterClientInterceptor(InterceptorMetadata, MetadataInterceptor(nil, false))
+	RegisterClientInterceptor(InterceptorForwardAuth, MetadataInterceptor(nil, true))
+}
+
+//return the interceptors of the names, in the same order, the "timing" counts in the timings of the conns
+func makeInterceptors(names []string, timings *timings) ([]grpc.UnaryClientInterceptor, []grpc.StreamClientInterceptor, error) {
+	var unary []grpc.UnaryClientInterceptor
+	var stream []grpc.StreamClientInterceptor
+	for _, name := range names {
+		it, ok := GetClientInterceptor(name)
+		if name == InterceptorTiming {
+			it, ok = ClientInterceptor{Unary: timings.unaryInterceptor, Stream: timings.streamInterceptor}, true
+		}
+		if !ok {
+			return nil, nil, dot.SError.NotExisted.AddNewError("client interceptor: " + name)
+		}
+		if it.Unary != nil {
+			unary = append(unary, it.Unary)
+		}
+		if it.Stream != nil {
+			stream = append(stream, it.Stream)
+		}
+	}
+	return unary, stream, nil
+}
+
+func logUnaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	logCall(method, start, err)
+	return err
+}
+
+func logStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	start := time.Now()
+	s, err := streamer(ctx, desc, cc, method, opts...)
+	logCall(method, start, err) //only the time of creating the stream
+	return s, err
+}
+
+func logCall(method string, start time.Time, err error) {
+	fields := []zap.Field{zap.String("method", method), zap.Duration("latency", time.Since(start)), zap.String("code", status.Code(err).String())}
+	if err != nil {
+		dot.Logger().Errorln("grpc client", append(fields, zap.Error(err))...)
+	} else {
+		dot.Logger().Infoln("grpc client", fields...)
+	}
+}
+
+//MetadataInterceptor propagate the auth token and request id to the outgoing metadata
+//the auth token: the token func, or the "authorization" of the incoming metadata if forwardAuth is true(if token is nil or return "")
+//the request id: the "x-request-id" of the incoming metadata, or the request id of the context(see dot.RequestId, gindot sets it in the context of the request), or a new one
+func MetadataInterceptor(token func(ctx context.Context) (string, error), forwardAuth bool) ClientInterceptor {
+	outgoing := func(ctx context.Context) (context.Context, error) {
+		out, _ := metadata.FromOutgoingContext(ctx)
+		in, _ := metadata.FromIncomingContext(ctx)
+		var kvs []string
+		if len(out.Get(AuthorizationKey)) < 1 {
+			auth := ""
+			if token != nil {
+				var err error
+				if auth, err = token(ctx); err != nil {
+					return nil, err
+				}
+			}
+			if len(auth) < 1 && forwardAuth {
+				if v := in.Get(AuthorizationKey); len(v) > 0 {
+					auth = v[0]
+				}
+			}
+			if len(auth) > 0 {
+				kvs = append(kvs, AuthorizationKey, auth)
+			}
+		}
+		if len(out.Get(RequestIdKey)) < 1 {
+			id := ""
+			if v := in.Get(RequestIdKey); len(v) > 0 {
+				id = v[0]
+			} else {
+				id = dot.RequestId(ctx)
+			}
+			if len(id) < 1 {
+				bs := make([]byte, 16)
+				_, _ = rand.Read(bs)
+				id = hex.EncodeToString(bs)
+			}
+			kvs = append(kvs, RequestIdKey, id)
+		}
+		if len(kvs) > 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, kvs...)
+		}
+		return ctx, nil
+	}
+
+	return ClientInterceptor{
+		Unary: func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			ctx, err := outgoing(ctx)
+			if err != nil {
+				return err
+			}
+			return invoker(ctx, method, req, reply, cc, opts...)
+		},
+		Stream: func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			ctx, err := outgoing(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return streamer(ctx, desc, cc, method, opts...)
+		},
+	}
+}
+
+//BearerToken return the token func of MetadataInterceptor, the value is "Bearer " + token
+func BearerToken(token func(ctx context.Context) (string, error)) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		t, err := token(ctx)
+		if err != nil || len(t) < 1 {
+			return "", err
+		}
+		if !strings.HasPrefix(t, "Bearer ") {
+			t = "Bearer " + t
+		}
+		return t, nil
+	}
+}
+
+//Timing the latency of one method
+type Timing struct {
+	Method string
+	Count  int64
+	Errors int64
+	Total  time.Duration
+	Max    time.Duration
+}
+
+//the timings of one conns
+type timings struct {
+	mutex   sync.Mutex
+	methods map[string]*Timing
+}
+
+func newTimings() *timings {
+	return &timings{methods: make(map[string]*Timing)}
+}
+
+//list return the timings sorted by the method
+func (c *timings) list() []Timing {
+	c.mutex.Lock()
+	res := make([]Timing, 0, len(c.methods))
+	for _, it := range c.methods {
+		res = append(res, *it)
+	}
+	c.mutex.Unlock()
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Method < res[j].Method
+	})
+	return res
+}
+
+func (c *timings) add(method string, start time.Time, err error) {
+	d := time.Since(start)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	t := c.methods[method]
+	if t == nil {
+		t = &Timing{Method: method}
+		c.methods[method] = t
+	}
+	t.Count++
+	if err != nil {
+		t.Errors++
+	}
+	t.Total += d
+	if d > t.Max {
+		t.Max = d
+	}
+}
+
+func (c *timings) unaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	c.add(method, start, err)
+	return err
+}
+
+func (c *timings) streamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	start := time.Now()
+	s, err := streamer(ctx, desc, cc, method, opts...)
+	c.add(method, start, err) //only the time of creating the stream
+	return s, err
+}
@@ -0,0 +1,100 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package conns
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/scryinfo/dot/dot"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestConns_Interceptors(t *testing.T) {
+	h, addr, stop := startTestServer(t, func(n int32) error { return nil })
+	defer stop()
+
+	var order []string
+	mark := func(name string) ClientInterceptor {
+		return ClientInterceptor{Unary: func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			order = append(order, name)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}}
+	}
+	RegisterClientInterceptor("test1", mark("test1"))
+	RegisterClientInterceptor("test2", mark("test2"))
+	RegisterClientInterceptor("auth", MetadataInterceptor(BearerToken(func(ctx context.Context) (string, error) {
+		return "token", nil
+	}), false))
+
+	d, _ := newConns([]byte(`{"scheme":"interceptor","services":[{"name":"hi","interceptors":["unknown"]}]}`))
+	if err := d.(*connsImp).Create(nil); err == nil {
+		t.Error("the unknown interceptor")
+	}
+
+	c := newCallConns(t, addr, `"interceptors":["test2","timing","auth","test1","log"]`)
+	defer c.Stop(false)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIdKey, "id1"))
+	if _, err := grpc_health_v1.NewHealthClient(c.ClientConn("hi")).Check(ctx, &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 2 || order[0] != "test2" || order[1] != "test1" {
+		t.Error(order)
+	}
+	h.mutex.Lock()
+	md := h.md
+	h.mutex.Unlock()
+	if v := md.Get(AuthorizationKey); len(v) != 1 || v[0] != "Bearer token" {
+		t.Error(md)
+	}
+	if v := md.Get(RequestIdKey); len(v) != 1 || v[0] != "id1" {
+		t.Error(md)
+	}
+
+	found := false
+	for _, it := range c.Timings() {
+		if it.Method == "/grpc.health.v1.Health/Check" && it.Count > 0 {
+			found = true
+		}
+	}
+	if !found {
+		t.Error(c.Timings())
+	}
+}
+
+func TestConns_MetadataInterceptor(t *testing.T) {
+	h, addr, stop := startTestServer(t, func(n int32) error { return nil })
+	defer stop()
+
+	in := metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationKey, "Bearer in"))
+	idCtx := dot.WithRequestId(context.Background(), "id2") //sample: the context of the request of gindot
+	for i, it := range []struct {
+		interceptor string
+		ctx         context.Context
+		auth, id    string //"" id means a new one
+	}{
+		{InterceptorMetadata, in, "", ""}, //do not forward the token by default
+		{InterceptorForwardAuth, in, "Bearer in", ""},
+		{InterceptorMetadata, idCtx, "", "id2"},
+	} {
+		c := newCallConns(t, addr, `"interceptors":["`+it.interceptor+`"]`)
+		_, err := grpc_health_v1.NewHealthClient(c.ClientConn("hi")).Check(it.ctx, &grpc_health_v1.HealthCheckRequest{})
+		_ = c.Stop(false)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		h.mutex.Lock()
+		md := h.md
+		h.mutex.Unlock()
+		if auth := strings.Join(md.Get(AuthorizationKey), ""); auth != it.auth {
+			t.Error(i, md)
+		}
+		if id := strings.Join(md.Get(RequestIdKey), ""); len(id) < 1 || (len(it.id) > 0 && id != it.id) {
+			t.Error(i, md)
+		}
+	}
+}
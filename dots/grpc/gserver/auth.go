@@ -0,0 +1,278 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gserver
+
+import (
+	"context"
+	"crypto/x509"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/scryinfo/dot/dot"
+	"github.com/scryinfo/dot/dots/certificate"
+	"github.com/scryinfo/dot/dots/grpc/shared"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	AuthTypeId = "a59748c4-3baa-480a-9150-4a59454c2aba"
+
+	//the default name of the registered server interceptor
+	AuthInterceptorName = "auth"
+
+	PrincipalMtls = "mtls"
+	PrincipalJwt  = "jwt"
+
+	//the prefixes of the allowed identities, so the "sub" of jwt can not be the same as the certificate of a service
+	AllowJwtPrefix  = "jwt:"
+	AllowCertPrefix = "cert:"
+)
+
+type authConfig struct {
+	Name      string     `json:"name"`      //the name of the registered server interceptor, the default value is "auth"
+	Hmac      string     `json:"hmac"`      //the secret of jwt HS256, HS384 and HS512
+	EcdsaPems []string   `json:"ecdsaPems"` //the certificate files(dots/certificate can make them) of jwt ES256, ES384 and ES512
+	Issuer    string     `json:"issuer"`    //if it is not empty, the "iss" of jwt must be the same
+	Audience  string     `json:"audience"`  //if it is not empty, the "aud" of jwt must contain it
+	Rules     []authRule `json:"rules"`     //the first matched rule is used; if no rule is matched, any principal is allowed
+}
+
+type authRule struct {
+	//the full method, sample: "/pkg.Service/Method", "/pkg.Service/*" or "*"
+	Method string `json:"method"`
+	//no principal is needed
+	Anonymous bool `json:"anonymous"`
+	//"jwt:" + the "sub" of jwt, or "cert:" + the common name or the dns name of certificate, sample: ["jwt:alice", "cert:client.scry"]
+	//"*" means any principal, "jwt:*" or "cert:*" means any principal of the type
+	Allow []string `json:"allow"`
+}
+
+//Principal the authenticated identity of the call
+type Principal struct {
+	Type        string                 //PrincipalMtls or PrincipalJwt
+	Name        string                 //the "sub" of jwt, or the common name of the certificate
+	DnsNames    []string               //the dns names(SANs) of the certificate
+	Claims      map[string]interface{} //the claims of jwt
+	Certificate *x509.Certificate      //the verified client certificate
+}
+
+type principalKey struct{}
+
+//PrincipalFromContext return the principal of the call, it is nil if the call is anonymous
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+//ContextWithPrincipal return the context with the principal
+func ContextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+//Auth authenticate the call by the bearer jwt of metadata "authorization", or the mtls client certificate
+//then check the rules of the method, and put the principal into the context
+//the interceptor is registered in Create, add the name to the "interceptors" of the server config
+type Auth struct {
+	conf authConfig
+	jwt  *jwtVerifier
+}
+
+func newAuth(conf interface{}) (dot.Dot, error) {
+	var err error = nil
+	var bs []byte = nil
+	if bt, ok := conf.([]byte); ok {
+		bs = bt
+	} else {
+		return nil, dot.SError.Parameter
+	}
+	dconf := &authConfig{}
+	err = dot.UnMarshalConfig(bs, dconf)
+	if err != nil {
+		return nil, err
+	}
+	if len(dconf.Name) < 1 {
+		dconf.Name = AuthInterceptorName
+	}
+	for _, r := range dconf.Rules {
+		for _, it := range r.Allow {
+			if it != "*" && !strings.HasPrefix(it, AllowJwtPrefix) && !strings.HasPrefix(it, AllowCertPrefix) {
+				return nil, errors.New("the allow must be \"*\", \"jwt:name\" or \"cert:name\": " + it)
+			}
+		}
+	}
+
+	d := &Auth{
+		conf: *dconf,
+	}
+
+	return d, err
+}
+
+//AuthTypeLives Data structure needed when generating newer component
+func AuthTypeLives() *dot.TypeLives {
+	return &dot.TypeLives{
+		Meta: dot.Metadata{TypeId: AuthTypeId, NewDoter: func(conf interface{}) (dot dot.Dot, err error) {
+			return newAuth(conf)
+		}},
+	}
+}
+
+//AuthConfigTypeLives return config of Auth
+func AuthConfigTypeLives() *dot.ConfigTypeLives {
+	return &dot.ConfigTypeLives{
+		TypeIdConfig: AuthTypeId,
+		ConfigInfo: &authConfig{
+			Rules: []authRule{{Method: "*", Allow: []string{"*"}}},
+		},
+	}
+}
+
+func (c *Auth) Create(l dot.Line) error {
+	c.jwt = &jwtVerifier{
+		hmac:     []byte(c.conf.Hmac),
+		issuer:   c.conf.Issuer,
+		audience: c.conf.Audience,
+	}
+	ec := &certificate.Ecdsa{}
+	for _, it := range c.conf.EcdsaPems {
+		file := shared.GetFullPathFile(it)
+		if len(file) < 1 {
+			return errors.New("the ecdsa pem is not empty, and can not find the file: " + it)
+		}
+		pub, err := ec.PublicKey(file)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		c.jwt.ecdsa = append(c.jwt.ecdsa, pub)
+	}
+
+	RegisterServerInterceptor(c.conf.Name, ServerInterceptor{Unary: c.UnaryInterceptor, Stream: c.StreamInterceptor})
+	return nil
+}
+
+//UnaryInterceptor authenticate and authorize the unary call
+func (c *Auth) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := c.Authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+//StreamInterceptor authenticate and authorize the stream call
+func (c *Auth) StreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := c.Authorize(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &principalStream{ServerStream: stream, ctx: ctx})
+}
+
+//Authorize return the context with the principal, or the error of codes.Unauthenticated or codes.PermissionDenied
+func (c *Auth) Authorize(ctx context.Context, fullMethod string) (context.Context, error) {
+	p, err := c.authenticate(ctx)
+	if err != nil {
+		dot.Logger().Debugln("Auth", zap.String("method", fullMethod), zap.Error(err))
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	rule := c.rule(fullMethod)
+	switch {
+	case rule != nil && rule.Anonymous:
+	case p == nil:
+		return nil, status.Error(codes.Unauthenticated, "no token or client certificate")
+	case rule != nil && !rule.allow(p):
+		return nil, status.Error(codes.PermissionDenied, "the principal is not allowed: "+p.Name)
+	}
+	if p != nil {
+		ctx = ContextWithPrincipal(ctx, p)
+	}
+	return ctx, nil
+}
+
+//return nil principal if there is no token or certificate
+func (c *Auth) authenticate(ctx context.Context) (*Principal, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("authorization"); len(v) > 0 {
+			const bearer = "bearer "
+			if len(v[0]) <= len(bearer) || !strings.EqualFold(v[0][:len(bearer)], bearer) {
+				return nil, errors.New("the authorization is not bearer token")
+			}
+			claims, err := c.jwt.verify(strings.TrimSpace(v[0][len(bearer):]))
+			if err != nil {
+				return nil, err
+			}
+			p := &Principal{Type: PrincipalJwt, Claims: claims}
+			p.Name, _ = claims["sub"].(string)
+			return p, nil
+		}
+	}
+
+	if pr, ok := peer.FromContext(ctx); ok {
+		if info, ok := pr.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 && len(info.State.VerifiedChains[0]) > 0 {
+			cert := info.State.VerifiedChains[0][0]
+			return &Principal{
+				Type:        PrincipalMtls,
+				Name:        cert.Subject.CommonName,
+				DnsNames:    cert.DNSNames,
+				Certificate: cert,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *Auth) rule(fullMethod string) *authRule {
+	for i := range c.conf.Rules {
+		r := &c.conf.Rules[i]
+		switch {
+		case r.Method == "*" || r.Method == fullMethod:
+			return r
+		case strings.HasSuffix(r.Method, "/*") && strings.HasPrefix(fullMethod, r.Method[:len(r.Method)-1]):
+			return r
+		}
+	}
+	return nil
+}
+
+func (c *authRule) allow(p *Principal) bool {
+	prefix := AllowJwtPrefix
+	if p.Type == PrincipalMtls {
+		prefix = AllowCertPrefix
+	}
+	for _, it := range c.Allow {
+		if it == "*" {
+			return true
+		}
+		if !strings.HasPrefix(it, prefix) {
+			continue
+		}
+		if name := it[len(prefix):]; name == "*" || name == p.Name {
+			return true
+		} else if p.Type == PrincipalMtls {
+			for _, dns := range p.DnsNames {
+				if name == dns {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+//replace the context of the stream
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (c *principalStream) Context() context.Context {
+	return c.ctx
+}
@@ -0,0 +1,128 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scryinfo/dot/dots/certificate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func newTestAuth(t *testing.T, conf string) *Auth {
+	d, err := newAuth([]byte(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := d.(*Auth)
+	if err = a.Create(nil); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func bearerCtx(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestAuth_Jwt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pri, _ := certificate.MakePriKey()
+	pem := filepath.Join(dir, "jwt.pem")
+	if _, err = (&certificate.Ecdsa{}).GenerateCaCertKey(pri, filepath.Join(dir, "jwt.key"), pem, []string{"scry"}, []string{"scry"}); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAuth(t, `{"hmac":"secret","ecdsaPems":["`+pem+`"],"issuer":"scry","rules":[
+		{"method":"/test.Hi/Public","anonymous":true},
+		{"method":"/test.Hi/*","allow":["jwt:alice","cert:bob"]},
+		{"method":"*","allow":["*"]}]}`)
+
+	hs, _ := MakeJwt(map[string]interface{}{"sub": "alice", "iss": "scry", "exp": time.Now().Add(time.Hour).Unix()}, []byte("secret"))
+	es, _ := MakeJwt(map[string]interface{}{"sub": "bob", "iss": "scry"}, pri)
+	expired, _ := MakeJwt(map[string]interface{}{"sub": "alice", "iss": "scry", "exp": time.Now().Add(-time.Hour).Unix()}, []byte("secret"))
+	wrongKey, _ := MakeJwt(map[string]interface{}{"sub": "alice", "iss": "scry"}, []byte("wrong"))
+	wrongIss, _ := MakeJwt(map[string]interface{}{"sub": "alice"}, []byte("secret"))
+
+	cases := []struct {
+		ctx    context.Context
+		method string
+		code   codes.Code
+		name   string
+	}{
+		{bearerCtx(hs), "/test.Hi/Say", codes.OK, "alice"},
+		{bearerCtx(es), "/test.Hi/Say", codes.PermissionDenied, ""},
+		{bearerCtx(es), "/test.Other/Say", codes.OK, "bob"},
+		{bearerCtx(es), "/test.Hi/Public", codes.OK, "bob"},
+		{context.Background(), "/test.Hi/Public", codes.OK, ""},
+		{context.Background(), "/test.Other/Say", codes.Unauthenticated, ""},
+		{bearerCtx(expired), "/test.Other/Say", codes.Unauthenticated, ""},
+		{bearerCtx(wrongKey), "/test.Other/Say", codes.Unauthenticated, ""},
+		{bearerCtx(wrongIss), "/test.Other/Say", codes.Unauthenticated, ""},
+		{bearerCtx("a.b"), "/test.Hi/Public", codes.Unauthenticated, ""},
+	}
+	for i, it := range cases {
+		ctx, err := a.Authorize(it.ctx, it.method)
+		if status.Code(err) != it.code {
+			t.Error(i, err)
+			continue
+		}
+		if err == nil {
+			name := ""
+			if p := PrincipalFromContext(ctx); p != nil {
+				name = p.Name
+			}
+			if name != it.name {
+				t.Error(i, name)
+			}
+		}
+	}
+}
+
+func TestAuth_Mtls(t *testing.T) {
+	a := newTestAuth(t, `{"hmac":"secret","rules":[{"method":"*","allow":["cert:client.scry"]}]}`)
+	mtlsCtx := func(dnsName string) context.Context {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: "scry"}, DNSNames: []string{dnsName}}
+		return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		}})
+	}
+
+	ctx, err := a.Authorize(mtlsCtx("client.scry"), "/test.Hi/Say")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := PrincipalFromContext(ctx); p == nil || p.Type != PrincipalMtls || p.Name != "scry" {
+		t.Error(p)
+	}
+	if _, err = a.Authorize(mtlsCtx("other.scry"), "/test.Hi/Say"); status.Code(err) != codes.PermissionDenied {
+		t.Error(err)
+	}
+	token, _ := MakeJwt(map[string]interface{}{"sub": "client.scry"}, []byte("secret")) //the same name as the certificate
+	if _, err = a.Authorize(bearerCtx(token), "/test.Hi/Say"); status.Code(err) != codes.PermissionDenied {
+		t.Error("the jwt gets the access of the certificate", err)
+	}
+	if _, err = newAuth([]byte(`{"rules":[{"method":"*","allow":["client.scry"]}]}`)); err == nil {
+		t.Error("the allow without the prefix is accepted")
+	}
+	if _, ok := GetServerInterceptor(AuthInterceptorName); !ok {
+		t.Error("the interceptor is not registered")
+	}
+}
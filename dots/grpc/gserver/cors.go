@@ -0,0 +1,208 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gserver
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//ConfigCors the cors policy of the grpc-web
+//if the AllowedOrigins is not set, any origin is allowed(the same as before), if it is empty("allowedOrigins":[]), only the same origin is allowed
+//the requests without "Origin" header(not from browser) are not checked
+type ConfigCors struct {
+	//sample: "https://a.com"(exact), "https://*.a.com"(wildcard subdomain), "regex:^https://[a-z]+\\.a\\.com$"(regex), "*"(any origin, do not use it in production)
+	AllowedOrigins []string `json:"allowedOrigins"`
+	//the request headers, "content-type", "x-grpc-web", "x-user-agent" and "grpc-timeout" are always allowed, "*" means any header
+	AllowedHeaders []string `json:"allowedHeaders"`
+	//the response headers that the browser can read, "grpc-status" and "grpc-message" are always exposed
+	//if it is empty, all response headers are exposed(the default of grpc-web)
+	ExposedHeaders []string `json:"exposedHeaders"`
+	//allow the cookies and authorization header, it can not be used with the "*" origin
+	AllowCredentials bool `json:"allowCredentials"`
+	//second, the browser cache the result of the preflight, 0 means no "Access-Control-Max-Age"
+	MaxAge int `json:"maxAge"`
+}
+
+var (
+	corsDefaultHeaders = []string{"content-type", "x-grpc-web", "x-user-agent", "grpc-timeout"}
+	corsDefaultExposed = []string{"grpc-status", "grpc-message"}
+)
+
+type corsPolicy struct {
+	conf      ConfigCors
+	any       bool
+	origins   map[string]bool
+	wildcards [][2]string //prefix and suffix
+	regexps   []*regexp.Regexp
+	headers   map[string]bool
+	anyHeader bool
+	exposed   string
+}
+
+func newCorsPolicy(conf ConfigCors) (*corsPolicy, error) {
+	c := &corsPolicy{
+		conf:    conf,
+		origins: make(map[string]bool, len(conf.AllowedOrigins)),
+		headers: make(map[string]bool, len(corsDefaultHeaders)+len(conf.AllowedHeaders)),
+	}
+	origins := conf.AllowedOrigins
+	if origins == nil {
+		origins = []string{"*"}
+	}
+	for _, it := range origins {
+		switch {
+		case it == "*":
+			c.any = true
+		case strings.HasPrefix(it, "regex:"):
+			r, err := regexp.Compile(it[len("regex:"):])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			c.regexps = append(c.regexps, r)
+		case strings.Contains(it, "*"):
+			index := strings.Index(it, "*")
+			if !strings.HasSuffix(it[:index], "://") || !strings.HasPrefix(it[index+1:], ".") || strings.Contains(it[index+1:], "*") {
+				return nil, errors.New("cors: the wildcard origin must be the subdomain, sample: https://*.a.com, but it is " + it)
+			}
+			c.wildcards = append(c.wildcards, [2]string{strings.ToLower(it[:index]), strings.ToLower(it[index+1:])})
+		default:
+			c.origins[strings.ToLower(it)] = true
+		}
+	}
+	if c.any && conf.AllowCredentials {
+		return nil, errors.New("cors: the origin \"*\" can not be used with the allowCredentials, set the allowedOrigins")
+	}
+	for _, it := range append(corsDefaultHeaders, conf.AllowedHeaders...) {
+		if it == "*" {
+			c.anyHeader = true
+		}
+		c.headers[strings.ToLower(it)] = true
+	}
+	if len(conf.ExposedHeaders) > 0 {
+		c.exposed = strings.Join(append(append([]string{}, corsDefaultExposed...), conf.ExposedHeaders...), ", ")
+	}
+	return c, nil
+}
+
+func (c *corsPolicy) allowOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	if c.any || c.origins[origin] {
+		return true
+	}
+	for _, it := range c.wildcards {
+		if len(origin) > len(it[0])+len(it[1]) && strings.HasPrefix(origin, it[0]) && strings.HasSuffix(origin, it[1]) {
+			if sub := origin[len(it[0]) : len(origin)-len(it[1])]; !strings.ContainsAny(sub, "/:") {
+				return true
+			}
+		}
+	}
+	for _, it := range c.regexps {
+		if it.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+//handle answer the preflight and reject the disallowed origin, if the request is finished, return true
+//or set the cors headers and return the response writer for the grpc-web
+func (c *corsPolicy) handle(resp http.ResponseWriter, req *http.Request) (http.ResponseWriter, bool) {
+	origin := req.Header.Get("Origin")
+	if len(origin) < 1 {
+		return resp, false
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, req.Host) { //same origin
+		return resp, false
+	}
+
+	h := resp.Header()
+	h.Add("Vary", "Origin")
+	if !c.allowOrigin(origin) {
+		http.Error(resp, "cors: the origin is not allowed: "+origin, http.StatusForbidden)
+		return resp, true
+	}
+	if c.any {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.conf.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	method := req.Header.Get("Access-Control-Request-Method")
+	if req.Method != http.MethodOptions || len(method) < 1 { //the actual request
+		if len(c.exposed) > 0 {
+			return &corsWriter{ResponseWriter: resp, exposed: c.exposed}, false
+		}
+		return resp, false
+	}
+
+	//preflight
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	if method != http.MethodPost { //all calls of grpc-web are post
+		http.Error(resp, "cors: the method is not allowed: "+method, http.StatusForbidden)
+		return resp, true
+	}
+	headers := req.Header.Get("Access-Control-Request-Headers")
+	if !c.anyHeader {
+		for _, it := range strings.Split(headers, ",") {
+			if it = strings.ToLower(strings.TrimSpace(it)); len(it) > 0 && !c.headers[it] {
+				http.Error(resp, "cors: the header is not allowed: "+it, http.StatusForbidden)
+				return resp, true
+			}
+		}
+	}
+	h.Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	if len(headers) > 0 {
+		h.Set("Access-Control-Allow-Headers", headers)
+	}
+	if c.conf.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(c.conf.MaxAge))
+	}
+	resp.WriteHeader(http.StatusNoContent)
+	return resp, true
+}
+
+//the grpc-web exposes all response headers when it writes the header, replace them with the configured ones
+type corsWriter struct {
+	http.ResponseWriter
+	exposed     string
+	wroteHeader bool
+}
+
+func (c *corsWriter) WriteHeader(code int) {
+	if !c.wroteHeader {
+		c.wroteHeader = true
+		c.ResponseWriter.Header().Set("Access-Control-Expose-Headers", c.exposed)
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *corsWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *corsWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *corsWriter) CloseNotify() <-chan bool {
+	if n, ok := c.ResponseWriter.(http.CloseNotifier); ok {
+		return n.CloseNotify()
+	}
+	return make(chan bool)
+}
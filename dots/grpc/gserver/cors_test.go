@@ -0,0 +1,115 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCorsPolicy(t *testing.T) {
+	for _, it := range []ConfigCors{
+		{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		{AllowedOrigins: []string{"https://a.*.com"}},
+		{AllowedOrigins: []string{"regex:("}},
+	} {
+		if _, err := newCorsPolicy(it); err == nil {
+			t.Error("no error", it)
+		}
+	}
+
+	c, err := newCorsPolicy(ConfigCors{
+		AllowedOrigins:   []string{"https://a.com", "https://*.b.com", `regex:^http://[a-z]+\.c\.com:8080$`},
+		AllowedHeaders:   []string{"Authorization"},
+		ExposedHeaders:   []string{"x-trace-id"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(method string, origin string, headers map[string]string) (*httptest.ResponseRecorder, http.ResponseWriter, bool) {
+		req := httptest.NewRequest(method, "http://server.com/pkg.Service/Method", nil)
+		if len(origin) > 0 {
+			req.Header.Set("Origin", origin)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		w, done := c.handle(rec, req)
+		return rec, w, done
+	}
+	preflight := func(headers string) map[string]string {
+		return map[string]string{"Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": headers}
+	}
+
+	//the allowed origins
+	for _, origin := range []string{"https://a.com", "https://x.b.com", "https://x.y.b.com", "http://x.c.com:8080"} {
+		rec, _, done := request(http.MethodOptions, origin, preflight("content-type,x-grpc-web,authorization"))
+		if !done || rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != origin ||
+			rec.Header().Get("Access-Control-Allow-Credentials") != "true" || rec.Header().Get("Access-Control-Max-Age") != "600" {
+			t.Error(origin, rec.Code, rec.Header())
+		}
+	}
+	//the disallowed origins
+	for _, origin := range []string{"https://b.com", "https://x.b.com.evil.com", "http://a.com", "http://x.c.com"} {
+		if rec, _, done := request(http.MethodPost, origin, nil); !done || rec.Code != http.StatusForbidden || len(rec.Header().Get("Access-Control-Allow-Origin")) > 0 {
+			t.Error(origin, rec.Code)
+		}
+	}
+	//the disallowed header and method
+	if rec, _, done := request(http.MethodOptions, "https://a.com", preflight("x-other")); !done || rec.Code != http.StatusForbidden {
+		t.Error(rec.Code)
+	}
+	if rec, _, done := request(http.MethodOptions, "https://a.com", map[string]string{"Access-Control-Request-Method": "PUT"}); !done || rec.Code != http.StatusForbidden {
+		t.Error(rec.Code)
+	}
+
+	//the actual request, the exposed headers replace the ones of grpc-web
+	rec, w, done := request(http.MethodPost, "https://a.com", nil)
+	if done || rec.Header().Get("Access-Control-Allow-Origin") != "https://a.com" {
+		t.Error(done, rec.Header())
+	}
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Type, Grpc-Status, X-Secret")
+	w.WriteHeader(http.StatusOK)
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != "grpc-status, grpc-message, x-trace-id" {
+		t.Error(got)
+	}
+
+	//no origin or the same origin, do not check
+	for _, origin := range []string{"", "http://server.com"} {
+		if rec, _, done := request(http.MethodPost, origin, nil); done || len(rec.Header().Get("Access-Control-Allow-Origin")) > 0 {
+			t.Error(origin, done)
+		}
+	}
+}
+
+func TestCorsPolicy_Default(t *testing.T) {
+	for _, it := range []struct {
+		conf  string
+		allow bool
+	}{
+		{`{}`, true},                     //not set, any origin as before
+		{`{"allowedOrigins":[]}`, false}, //only the same origin
+	} {
+		conf := ConfigCors{}
+		if err := json.Unmarshal([]byte(it.conf), &conf); err != nil {
+			t.Fatal(err)
+		}
+		c, err := newCorsPolicy(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "http://server.com/pkg.Service/Method", nil)
+		req.Header.Set("Origin", "https://a.com")
+		rec := httptest.NewRecorder()
+		if _, done := c.handle(rec, req); done == it.allow || (rec.Header().Get("Access-Control-Allow-Origin") == "*") != it.allow {
+			t.Error(it.conf, done, rec.Header())
+		}
+	}
+}
@@ -0,0 +1,664 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/pkg/errors"
+	"github.com/scryinfo/dot/dot"
+	"github.com/scryinfo/dot/dots/gindot"
+	"github.com/scryinfo/dot/dots/grpc/shared"
+	"github.com/scryinfo/dot/dots/tlsdot"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	GatewayNoblTypeId = "2b0a3f57-5c6e-4f0e-9d4a-8e7c1f6b9a30"
+)
+
+type gatewayNoblConf struct {
+	//sample :  1.1.1.1:568, if it is empty, the routes are added to the gin router
+	Addr        string           `json:"addr"`
+	Tls         shared.TlsConfig `json:"tls"`
+	StopTimeout int              `json:"stopTimeout"` //second, see shutdownHttp
+	//the http headers that are passed to the grpc metadata, the headers with prefix "Grpc-Metadata-" are always passed(without the prefix)
+	//if it is empty, the default is ["Authorization"]
+	Headers      []string `json:"headers"`
+	EmitDefaults bool     `json:"emitDefaults"` //the json of the response contains the fields of the default value
+	OrigName     bool     `json:"origName"`     //the json of the response uses the field names of the proto file, not the lowerCamelCase
+	MaxBodySize  int      `json:"maxBodySize"`  //byte, the max size of the request body, 0 means the maxRecvMsgSize of the ServerNobl
+}
+
+//the json(rest) gateway of the grpc services that are registered on the ServerNobl, the calls do not go through the network
+//every unary method has a route "POST /package.Service/Method" with the request message in the body,
+//and the routes of the "google.api.http" annotations of the proto file
+type gatewayNobl struct {
+	conf       gatewayNoblConf
+	ServerNobl ServerNobl     `dot:""`
+	GinRouter  *gindot.Router `dot:""` //it is used only if the addr is empty
+	Tls        *tlsdot.Tls    `dot:""` //the tls of the line, see tlsdot.Get
+	listener   net.Listener
+	tlsConfig  *tls.Config //nil if no tls
+	httpServer *http.Server
+	headers    []string
+	routes     []*gatewayRoute
+	draining   int32 //atomic
+}
+
+type gatewayRoute struct {
+	fullMethod string //sample: /package.Service/Method
+	httpMethod string
+	path       string //the path of gin
+	body       string //"*": the request message, "": no body, other: the field of the request message
+	input      reflect.Type
+	output     reflect.Type
+}
+
+//Construct component
+func newGatewayNobl(conf interface{}) (dot.Dot, error) {
+	var err error = nil
+	var bs []byte = nil
+	if bt, ok := conf.([]byte); ok {
+		bs = bt
+	} else {
+		return nil, dot.SError.Parameter
+	}
+	dconf := &gatewayNoblConf{}
+	err = dot.UnMarshalConfig(bs, dconf)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &gatewayNobl{
+		conf:    *dconf,
+		headers: dconf.Headers,
+	}
+	if len(d.headers) < 1 {
+		d.headers = []string{"Authorization"}
+	}
+
+	return d, err
+}
+
+//GatewayNoblTypeLives Data structure needed when generating newer component, the routes are added to the gin router
+func GatewayNoblTypeLives() []*dot.TypeLives {
+	tl := &dot.TypeLives{
+		Meta: dot.Metadata{TypeId: GatewayNoblTypeId, NewDoter: func(conf interface{}) (dot dot.Dot, err error) {
+			return newGatewayNobl(conf)
+		}},
+		Lives: []dot.Live{
+			{
+				LiveId:    GatewayNoblTypeId,
+				RelyLives: map[string]dot.LiveId{"GinRouter": gindot.RouterTypeId, "ServerNobl": ServerNoblTypeId},
+			},
+		},
+	}
+
+	lives := []*dot.TypeLives{
+		tl, ServerNoblTypeLive(),
+	}
+	lives = append(lives, gindot.TypeLiveRouter()...)
+	return lives
+}
+
+//GatewayNoblListenTypeLives Data structure needed when generating newer component, the gateway listens the addr of config, no gin router
+func GatewayNoblListenTypeLives() []*dot.TypeLives {
+	tl := &dot.TypeLives{
+		Meta: dot.Metadata{TypeId: GatewayNoblTypeId, NewDoter: func(conf interface{}) (dot dot.Dot, err error) {
+			return newGatewayNobl(conf)
+		}},
+		Lives: []dot.Live{
+			{
+				LiveId:    GatewayNoblTypeId,
+				RelyLives: map[string]dot.LiveId{"ServerNobl": ServerNoblTypeId},
+			},
+		},
+	}
+
+	return []*dot.TypeLives{
+		tl, ServerNoblTypeLive(),
+	}
+}
+
+//GatewayNoblConfigTypeLives return config of gatewayNobl
+func GatewayNoblConfigTypeLives() *dot.ConfigTypeLives {
+	return &dot.ConfigTypeLives{
+		TypeIdConfig: GatewayNoblTypeId,
+		ConfigInfo:   &gatewayNoblConf{},
+	}
+}
+
+//Create listen the addr and make the tls config, if the addr is not empty
+func (c *gatewayNobl) Create(l dot.Line) error {
+	if c.Tls == nil {
+		c.Tls = tlsdot.Get(l)
+	}
+	if len(c.conf.Addr) < 1 {
+		return nil
+	}
+	var err error
+	if c.tlsConfig, err = c.Tls.ServerConfig(c.conf.Tls); err != nil {
+		return err
+	}
+	if c.listener, err = net.Listen("tcp", c.conf.Addr); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+//Start return the error if the addr is empty and the gin router has no engine
+func (c *gatewayNobl) Start(ignore bool) error {
+	if c.listener == nil && (c.GinRouter == nil || c.GinRouter.Router() == nil) {
+		return dot.SError.NotExisted.AddNewError("the gin router of the gatewayNobl(the addr is empty)")
+	}
+	return nil
+}
+
+//Run after every component finished start, this can ensure all service has been registered on grpc server
+func (c *gatewayNobl) AfterAllStart(l dot.Line) {
+	c.startServer()
+}
+
+//Stop stop dot
+//the routes of gin can not be removed, so reject the new requests
+func (c *gatewayNobl) Stop(ignore bool) error {
+	atomic.StoreInt32(&c.draining, 1)
+	if c.httpServer != nil {
+		shutdownHttp(c.httpServer, c.conf.StopTimeout, "gatewayNobl")
+		c.httpServer = nil
+	} else if c.listener != nil {
+		_ = c.listener.Close()
+	}
+	c.listener = nil
+	return nil
+}
+
+func (c *gatewayNobl) Server() *grpc.Server {
+	return c.ServerNobl.Server()
+}
+
+//Addr return the address of the listener, nil if the routes are added to the gin router
+func (c *gatewayNobl) Addr() net.Addr {
+	if c.listener == nil {
+		return nil
+	}
+	return c.listener.Addr()
+}
+
+func (c *gatewayNobl) startServer() {
+	logger := dot.Logger()
+	var router gin.IRoutes
+	var engine *gin.Engine
+	if c.listener != nil {
+		engine = gin.New()
+		router = engine
+	} else if c.GinRouter != nil && c.GinRouter.Router() != nil {
+		router = c.GinRouter.Router()
+	} else {
+		logger.Errorln("gatewayNobl", zap.String("", "no addr and no gin router, the gateway does not start"))
+		return
+	}
+
+	c.routes = gatewayRoutes(c.Server())
+	for _, it := range c.routes {
+		if err := addGatewayRoute(router, it, c.handler(it)); err != nil {
+			logger.Warnln("gatewayNobl", zap.String("method", it.fullMethod), zap.Error(err))
+			continue
+		}
+		logger.Debugln("gatewayNobl", zap.String("method", it.fullMethod), zap.String("route", it.httpMethod+" "+it.path))
+	}
+
+	if engine == nil {
+		logger.Infoln("gatewayNobl", zap.String("", "the routes are added to the gin router"), zap.Int("routes", len(c.routes)))
+		return
+	}
+	c.httpServer = &http.Server{Handler: engine, TLSConfig: c.tlsConfig}
+	go func(s *http.Server, lis net.Listener) {
+		logger.Infoln("gatewayNobl", zap.String("", "gateway will start: "+lis.Addr().String()), zap.Int("routes", len(c.routes)), zap.Bool("tls", c.tlsConfig != nil))
+		var err error
+		if s.TLSConfig != nil {
+			err = s.ServeTLS(lis, "", "")
+		} else {
+			err = s.Serve(lis)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logger.Errorln("gatewayNobl", zap.Error(errors.WithStack(err)))
+		}
+	}(c.httpServer, c.listener)
+}
+
+//gin panics when the route is conflicted, return it as the error
+func addGatewayRoute(router gin.IRoutes, route *gatewayRoute, h gin.HandlerFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("gateway: can not add the route %s %s, %v", route.httpMethod, route.path, r)
+		}
+	}()
+	router.Handle(route.httpMethod, route.path, h)
+	return nil
+}
+
+func (c *gatewayNobl) handler(route *gatewayRoute) gin.HandlerFunc {
+	marshaler := &jsonpb.Marshaler{EmitDefaults: c.conf.EmitDefaults, OrigName: c.conf.OrigName}
+	maxBody := int64(c.conf.MaxBodySize)
+	if maxBody <= 0 {
+		maxBody = int64(c.ServerNobl.MaxRecvMsgSize())
+	}
+	return func(ctx *gin.Context) {
+		if atomic.LoadInt32(&c.draining) == 1 || c.ServerNobl.Draining() {
+			writeGatewayError(ctx, status.New(codes.Unavailable, "the server is stopping"))
+			return
+		}
+		in := reflect.New(route.input.Elem()).Interface().(proto.Message)
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBody)
+		if err := route.decode(ctx, in); err != nil {
+			writeGatewayError(ctx, status.New(codes.InvalidArgument, err.Error()))
+			return
+		}
+		out := reflect.New(route.output.Elem()).Interface().(proto.Message)
+		if st := c.invoke(ctx.Request, route.fullMethod, in, out); st.Code() != codes.OK {
+			writeGatewayError(ctx, st)
+			return
+		}
+		buf := &bytes.Buffer{}
+		if err := marshaler.Marshal(buf, out); err != nil {
+			writeGatewayError(ctx, status.New(codes.Internal, err.Error()))
+			return
+		}
+		ctx.Data(http.StatusOK, "application/json", buf.Bytes())
+	}
+}
+
+//invoke call the grpc server in process, like the grpc-web does, the request is a http/2 grpc request and the response is in the gatewayWriter
+func (c *gatewayNobl) invoke(req *http.Request, method string, in proto.Message, out proto.Message) *status.Status {
+	data, err := proto.Marshal(in)
+	if err != nil {
+		return status.New(codes.InvalidArgument, err.Error())
+	}
+	frame := make([]byte, 5+len(data)) //no compression, length, message
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(data)))
+	copy(frame[5:], data)
+
+	r, err := http.NewRequest(http.MethodPost, method, bytes.NewReader(frame))
+	if err != nil {
+		return status.New(codes.Internal, err.Error())
+	}
+	r = r.WithContext(req.Context())
+	r.ProtoMajor, r.ProtoMinor = 2, 0
+	r.Host, r.RemoteAddr, r.TLS = req.Host, req.RemoteAddr, req.TLS //the tls for the peer of the grpc(the client certificates)
+	r.Header.Set("content-type", "application/grpc")
+	for _, it := range c.headers {
+		for _, v := range req.Header[http.CanonicalHeaderKey(it)] {
+			r.Header.Add(it, v)
+		}
+	}
+	for k, vs := range req.Header {
+		if strings.HasPrefix(k, "Grpc-Metadata-") && len(k) > len("Grpc-Metadata-") {
+			for _, v := range vs {
+				r.Header.Add(k[len("Grpc-Metadata-"):], v)
+			}
+		}
+	}
+
+	w := &gatewayWriter{header: http.Header{}}
+	c.Server().ServeHTTP(w, r)
+
+	if w.code != 0 && w.code != http.StatusOK {
+		return status.New(codes.Internal, strings.TrimSpace(w.body.String()))
+	}
+	code, err := strconv.Atoi(w.header.Get("Grpc-Status"))
+	if err != nil {
+		return status.New(codes.Internal, "gateway: no grpc status")
+	}
+	if codes.Code(code) != codes.OK {
+		msg := w.header.Get("Grpc-Message")
+		if m, err := url.PathUnescape(msg); err == nil {
+			msg = m
+		}
+		return status.New(codes.Code(code), msg)
+	}
+	body := w.body.Bytes()
+	if len(body) < 5 || body[0] != 0 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+		return status.New(codes.Internal, "gateway: the response message is invalid")
+	}
+	if err = proto.Unmarshal(body[5:], out); err != nil {
+		return status.New(codes.Internal, err.Error())
+	}
+	return status.New(codes.OK, "")
+}
+
+//decode the request message from the body, the path parameters and the query(if the body is not the whole message)
+func (c *gatewayRoute) decode(ctx *gin.Context, in proto.Message) error {
+	if len(c.body) > 0 {
+		body, err := ioutil.ReadAll(ctx.Request.Body)
+		if err != nil {
+			return err
+		}
+		if body = bytes.TrimSpace(body); len(body) > 0 {
+			if c.body != "*" { //wrap to the message: {"a":{"b":body}}
+				names := strings.Split(c.body, ".")
+				for i := len(names) - 1; i >= 0; i-- {
+					name, _ := json.Marshal(names[i])
+					body = append(append(append([]byte("{"), name...), ':'), append(body, '}')...)
+				}
+			}
+			if err = jsonpb.Unmarshal(bytes.NewReader(body), in); err != nil {
+				return err
+			}
+		}
+	}
+
+	fields := map[string]interface{}{}
+	for _, p := range ctx.Params {
+		setGatewayField(fields, c.input, p.Key, []string{strings.TrimPrefix(p.Value, "/")})
+	}
+	if c.body != "*" {
+		for k, vs := range ctx.Request.URL.Query() {
+			setGatewayField(fields, c.input, k, vs)
+		}
+	}
+	if len(fields) < 1 {
+		return nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	params := reflect.New(c.input.Elem()).Interface().(proto.Message)
+	u := &jsonpb.Unmarshaler{AllowUnknownFields: true} //ignore the query parameters that are not the fields
+	if err = u.Unmarshal(bytes.NewReader(data), params); err != nil {
+		return err
+	}
+	proto.Merge(in, params)
+	return nil
+}
+
+//set the value of the field path(sample: "a.b") to the json object, the type of field decides the json value
+func setGatewayField(fields map[string]interface{}, t reflect.Type, path string, values []string) {
+	names := strings.Split(path, ".")
+	for _, name := range names[:len(names)-1] {
+		sub, ok := fields[name].(map[string]interface{})
+		if !ok {
+			sub = map[string]interface{}{}
+			fields[name] = sub
+		}
+		fields = sub
+	}
+	ft := gatewayFieldType(t, names)
+	value := func(v string) interface{} {
+		if ft != nil && (ft.Kind() == reflect.Bool || ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Bool) {
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b
+			}
+		}
+		return v //jsonpb accept the number and enum in the string
+	}
+	if ft != nil && ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.Uint8 {
+		list := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			list = append(list, value(v))
+		}
+		fields[names[len(names)-1]] = list
+	} else if len(values) > 0 {
+		fields[names[len(names)-1]] = value(values[0])
+	}
+}
+
+//return the go type of the field, nil if it is not found
+func gatewayFieldType(t reflect.Type, names []string) reflect.Type {
+	for _, name := range names {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+		var next reflect.Type
+		for _, p := range proto.GetProperties(t).Prop {
+			if p.OrigName == name || p.JSONName == name {
+				if f, ok := t.FieldByName(p.Name); ok {
+					next = f.Type
+				}
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		t = next
+	}
+	return t
+}
+
+//gatewayRoutes make the routes of the unary methods, the methods without proto descriptor are skipped
+func gatewayRoutes(server *grpc.Server) []*gatewayRoute {
+	logger := dot.Logger()
+	infos := server.GetServiceInfo()
+	names := make([]string, 0, len(infos))
+	for name := range infos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var routes []*gatewayRoute
+	for _, name := range names {
+		file, _ := infos[name].Metadata.(string)
+		sd, err := serviceDescriptor(file, name)
+		if err != nil {
+			logger.Warnln("gatewayNobl", zap.String("service", name), zap.Error(err))
+			continue
+		}
+		for _, m := range sd.Method {
+			fullMethod := "/" + name + "/" + m.GetName()
+			if m.GetClientStreaming() || m.GetServerStreaming() {
+				logger.Debugln("gatewayNobl", zap.String("", "skip the stream method: "+fullMethod))
+				continue
+			}
+			input, output := proto.MessageType(strings.TrimPrefix(m.GetInputType(), ".")), proto.MessageType(strings.TrimPrefix(m.GetOutputType(), "."))
+			if input == nil || output == nil {
+				logger.Warnln("gatewayNobl", zap.String("", "the message type is not registered, skip the method: "+fullMethod))
+				continue
+			}
+			route := gatewayRoute{fullMethod: fullMethod, httpMethod: http.MethodPost, path: fullMethod, body: "*", input: input, output: output}
+			routes = append(routes, &route)
+
+			if m.Options == nil || !proto.HasExtension(m.Options, annotations.E_Http) {
+				continue
+			}
+			ext, err := proto.GetExtension(m.Options, annotations.E_Http)
+			if err != nil {
+				logger.Warnln("gatewayNobl", zap.String("method", fullMethod), zap.Error(err))
+				continue
+			}
+			rule := ext.(*annotations.HttpRule)
+			for _, it := range append([]*annotations.HttpRule{rule}, rule.AdditionalBindings...) {
+				r := route
+				if err = r.bind(it); err != nil {
+					logger.Warnln("gatewayNobl", zap.String("method", fullMethod), zap.Error(err))
+					continue
+				}
+				routes = append(routes, &r)
+			}
+		}
+	}
+	return routes
+}
+
+//the metadata of the service is the proto file name, find the service in the registered file descriptor
+func serviceDescriptor(file string, service string) (*descriptor.ServiceDescriptorProto, error) {
+	gz := proto.FileDescriptor(file)
+	if len(gz) < 1 {
+		return nil, errors.New("gateway: the proto file is not registered: " + file)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fd := &descriptor.FileDescriptorProto{}
+	if err = proto.Unmarshal(data, fd); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, it := range fd.Service {
+		name := it.GetName()
+		if len(fd.GetPackage()) > 0 {
+			name = fd.GetPackage() + "." + name
+		}
+		if name == service {
+			return it, nil
+		}
+	}
+	return nil, errors.New("gateway: the service is not in the proto file: " + file)
+}
+
+//bind the route to the http rule
+func (c *gatewayRoute) bind(rule *annotations.HttpRule) error {
+	var template string
+	switch p := rule.Pattern.(type) {
+	case *annotations.HttpRule_Get:
+		c.httpMethod, template = http.MethodGet, p.Get
+	case *annotations.HttpRule_Put:
+		c.httpMethod, template = http.MethodPut, p.Put
+	case *annotations.HttpRule_Post:
+		c.httpMethod, template = http.MethodPost, p.Post
+	case *annotations.HttpRule_Delete:
+		c.httpMethod, template = http.MethodDelete, p.Delete
+	case *annotations.HttpRule_Patch:
+		c.httpMethod, template = http.MethodPatch, p.Patch
+	case *annotations.HttpRule_Custom:
+		c.httpMethod, template = strings.ToUpper(p.Custom.GetKind()), p.Custom.GetPath()
+	default:
+		return errors.New("gateway: no pattern of the http rule")
+	}
+	path, err := gatewayPath(template)
+	if err != nil {
+		return err
+	}
+	c.path, c.body = path, rule.Body
+	return nil
+}
+
+//gatewayPath convert the path template of the http rule to the path of gin
+//supported: "/v1/users/{id}", "/v1/users/{id=*}", "/v1/files/{name=**}"(the last segment)
+func gatewayPath(template string) (string, error) {
+	if !strings.HasPrefix(template, "/") {
+		return "", errors.New("gateway: the path template must start with \"/\": " + template)
+	}
+	segments := strings.Split(template[1:], "/")
+	for i, it := range segments {
+		if strings.ContainsAny(it, ":") {
+			return "", errors.New("gateway: the verb of the path template is not supported: " + template)
+		}
+		if !strings.HasPrefix(it, "{") {
+			if strings.ContainsAny(it, "*{}") {
+				return "", errors.New("gateway: the wildcard segment is not supported: " + template)
+			}
+			continue
+		}
+		if !strings.HasSuffix(it, "}") {
+			return "", errors.New("gateway: the variable must be one segment: " + template)
+		}
+		name, pattern := it[1:len(it)-1], "*"
+		if index := strings.Index(name, "="); index >= 0 {
+			name, pattern = name[:index], name[index+1:]
+		}
+		switch {
+		case len(name) < 1:
+			return "", errors.New("gateway: the variable has no name: " + template)
+		case pattern == "*":
+			segments[i] = ":" + name
+		case pattern == "**" && i == len(segments)-1:
+			segments[i] = "*" + name
+		default:
+			return "", errors.New("gateway: the variable pattern is not supported: " + template)
+		}
+	}
+	return "/" + strings.Join(segments, "/"), nil
+}
+
+//the status code of http for the grpc code, same as the grpc-gateway
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 //client closed request
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default: //Unknown, Internal, DataLoss
+		return http.StatusInternalServerError
+	}
+}
+
+func writeGatewayError(ctx *gin.Context, st *status.Status) {
+	ctx.JSON(httpStatusFromCode(st.Code()), gin.H{"code": int(st.Code()), "message": st.Message()})
+}
+
+//the response writer of the in process call
+type gatewayWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	code   int
+}
+
+func (c *gatewayWriter) Header() http.Header {
+	return c.header
+}
+
+func (c *gatewayWriter) Write(b []byte) (int, error) {
+	return c.body.Write(b)
+}
+
+func (c *gatewayWriter) WriteHeader(code int) {
+	if c.code == 0 {
+		c.code = code
+	}
+}
+
+func (c *gatewayWriter) Flush() {
+}
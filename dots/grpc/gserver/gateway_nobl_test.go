@@ -0,0 +1,205 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+//register a proto file with the http annotations, the messages are the ones of the grpc health
+func registerGatewayTestFile(t *testing.T) {
+	opts := &descriptor.MethodOptions{}
+	err := proto.SetExtension(opts, annotations.E_Http, &annotations.HttpRule{
+		Pattern:            &annotations.HttpRule_Get{Get: "/v1/health/{service}"},
+		AdditionalBindings: []*annotations.HttpRule{{Pattern: &annotations.HttpRule_Post{Post: "/v1/health"}, Body: "*"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := &descriptor.FileDescriptorProto{
+		Name:       proto.String("gserver/gateway_test.proto"),
+		Package:    proto.String("gserver.test"),
+		Dependency: []string{"grpc/health/v1/health.proto", "google/api/annotations.proto"},
+		Service: []*descriptor.ServiceDescriptorProto{{
+			Name: proto.String("Echo"),
+			Method: []*descriptor.MethodDescriptorProto{{
+				Name:       proto.String("Check"),
+				InputType:  proto.String(".grpc.health.v1.HealthCheckRequest"),
+				OutputType: proto.String(".grpc.health.v1.HealthCheckResponse"),
+				Options:    opts,
+			}},
+		}},
+	}
+	data, err := proto.Marshal(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	_, _ = w.Write(data)
+	_ = w.Close()
+	proto.RegisterFile(fd.GetName(), buf.Bytes())
+}
+
+//the echo checks the service and the "authorization" metadata
+var gatewayTestEcho = grpc.ServiceDesc{
+	ServiceName: "gserver.test.Echo",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Check",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := &grpc_health_v1.HealthCheckRequest{}
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			if md, _ := metadata.FromIncomingContext(ctx); strings.Join(md.Get("authorization"), "") != "Bearer t" {
+				return nil, status.Error(codes.Unauthenticated, "no token")
+			}
+			if p, ok := peer.FromContext(ctx); in.Service == "tls" && ok {
+				if _, ok = p.AuthInfo.(credentials.TLSInfo); ok {
+					return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+				}
+			}
+			if in.Service != "hi" {
+				return nil, status.Error(codes.NotFound, "unknown service")
+			}
+			return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+		},
+	}},
+	Metadata: "gserver/gateway_test.proto",
+}
+
+func TestGatewayNobl(t *testing.T) {
+	registerGatewayTestFile(t)
+	d, err := newServerNobl([]byte(`{"addrs":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := d.(*serverNoblImp)
+	if err = server.Create(nil); err != nil {
+		t.Fatal(err)
+	}
+	grpc_health_v1.RegisterHealthServer(server.Server(), health.NewServer())
+	server.Server().RegisterService(&gatewayTestEcho, struct{}{})
+
+	d, err = newGatewayNobl([]byte(`{"addr":"127.0.0.1:0","emitDefaults":true,"maxBodySize":64}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway := d.(*gatewayNobl)
+	gateway.ServerNobl = server
+	if err = gateway.Create(nil); err != nil {
+		t.Fatal(err)
+	}
+	gateway.AfterAllStart(nil)
+	defer func() {
+		_ = gateway.Stop(false)
+		_ = server.Stop(false)
+	}()
+
+	routes := map[string]bool{}
+	for _, it := range gateway.routes {
+		routes[it.httpMethod+" "+it.path] = true
+	}
+	for _, it := range []string{"POST /grpc.health.v1.Health/Check", "POST /gserver.test.Echo/Check", "GET /v1/health/:service", "POST /v1/health"} {
+		if !routes[it] {
+			t.Error("no route", it, routes)
+		}
+	}
+	if routes["POST /grpc.health.v1.Health/Watch"] {
+		t.Error("the stream method is added")
+	}
+
+	base := "http://" + gateway.Addr().String()
+	call := func(method string, path string, body string, token string) (int, string) {
+		req, _ := http.NewRequest(method, base+path, strings.NewReader(body))
+		if len(token) > 0 {
+			req.Header.Set("Authorization", token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, strings.TrimSpace(string(data))
+	}
+
+	cases := []struct {
+		method, path, body, token string
+		code                      int
+		resp                      string
+	}{
+		{http.MethodPost, "/grpc.health.v1.Health/Check", `{}`, "", http.StatusOK, `{"status":"SERVING"}`},
+		{http.MethodPost, "/grpc.health.v1.Health/Check", ``, "", http.StatusOK, `{"status":"SERVING"}`},
+		{http.MethodPost, "/grpc.health.v1.Health/Check", `{"service":"none"}`, "", http.StatusNotFound, `{"code":5,"message":"unknown service"}`},
+		{http.MethodPost, "/grpc.health.v1.Health/Check", `{"none":1}`, "", http.StatusBadRequest, ""},
+		{http.MethodGet, "/v1/health/hi?other=1", ``, "Bearer t", http.StatusOK, `{"status":"SERVING"}`},
+		{http.MethodGet, "/v1/health/none", ``, "Bearer t", http.StatusNotFound, ""},
+		{http.MethodGet, "/v1/health/hi", ``, "", http.StatusUnauthorized, `{"code":16,"message":"no token"}`},
+		{http.MethodPost, "/v1/health", `{"service":"hi"}`, "Bearer t", http.StatusOK, `{"status":"SERVING"}`},
+		{http.MethodPost, "/gserver.test.Echo/Check", `{"service":"hi"}`, "Bearer t", http.StatusOK, `{"status":"SERVING"}`},
+		{http.MethodPost, "/v1/health", `{"service":"` + strings.Repeat("a", 64) + `"}`, "Bearer t", http.StatusBadRequest, `{"code":3,"message":"http: request body too large"}`},
+	}
+	for i, it := range cases {
+		code, resp := call(it.method, it.path, it.body, it.token)
+		if code != it.code || (len(it.resp) > 0 && resp != it.resp) {
+			t.Error(i, code, resp)
+		}
+	}
+
+	//the tls of the http request is the peer of the grpc
+	req, _ := http.NewRequest(http.MethodPost, base, nil)
+	req.Header.Set("Authorization", "Bearer t")
+	out := &grpc_health_v1.HealthCheckResponse{}
+	if st := gateway.invoke(req, "/gserver.test.Echo/Check", &grpc_health_v1.HealthCheckRequest{Service: "tls"}, out); st.Code() != codes.NotFound {
+		t.Error(st)
+	}
+	req.TLS = &tls.ConnectionState{}
+	if st := gateway.invoke(req, "/gserver.test.Echo/Check", &grpc_health_v1.HealthCheckRequest{Service: "tls"}, out); st.Code() != codes.OK {
+		t.Error(st)
+	}
+
+	atomic.StoreInt32(&gateway.draining, 1)
+	if code, _ := call(http.MethodPost, "/grpc.health.v1.Health/Check", `{}`, ""); code != http.StatusServiceUnavailable {
+		t.Error(code)
+	}
+}
+
+func TestGatewayPath(t *testing.T) {
+	for template, want := range map[string]string{
+		"/v1/users/{id}":           "/v1/users/:id",
+		"/v1/users/{user.id=*}/a":  "/v1/users/:user.id/a",
+		"/v1/files/{name=**}":      "/v1/files/*name",
+		"v1/users":                 "",
+		"/v1/users/{id}:get":       "",
+		"/v1/{name=users/*}":       "",
+		"/v1/*/users":              "",
+		"/v1/files/{name=**}/more": "",
+	} {
+		if got, err := gatewayPath(template); got != want || (err == nil) != (len(want) > 0) {
+			t.Error(template, got, err)
+		}
+	}
+}
@@ -12,18 +12,50 @@ import (
 	"google.golang.org/grpc"
 	"net/http"
 	"strings"
+	"sync/atomic"
 )
 
 const (
 	GinNoblTypeId = "3c9e8119-3d42-45bd-98f9-32939c895c6d"
 )
 
+type ginNoblConf struct {
+	Cors ConfigCors `json:"cors"`
+}
+
 //support the http and tcp
 type ginNobl struct {
+	conf       ginNoblConf
 	ServerNobl ServerNobl     `dot:""`
 	GinRouter  *gindot.Router `dot:""`
 	wrapserver *grpcweb.WrappedGrpcServer
+	cors       *corsPolicy
 	preUrl     string
+	draining   int32 //atomic
+}
+
+//Construct component, the config can be empty
+func newGinNobl(conf interface{}) (dot.Dot, error) {
+	var err error = nil
+	var bs []byte = nil
+	if bt, ok := conf.([]byte); ok {
+		bs = bt
+	} else if conf != nil {
+		return nil, dot.SError.Parameter
+	}
+	dconf := &ginNoblConf{}
+	if len(bs) > 0 {
+		err = dot.UnMarshalConfig(bs, dconf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	d := &ginNobl{
+		conf: *dconf,
+	}
+
+	return d, err
 }
 
 //GinNoblTypeLives Data structure needed when generating newer component
@@ -31,7 +63,7 @@ func GinNoblTypeLives() []*dot.TypeLives {
 
 	tl := &dot.TypeLives{
 		Meta: dot.Metadata{TypeId: GinNoblTypeId, NewDoter: func(conf interface{}) (dot dot.Dot, err error) {
-			return &ginNobl{}, nil
+			return newGinNobl(conf)
 		}},
 		Lives: []dot.Live{
 			dot.Live{
@@ -49,6 +81,29 @@ func GinNoblTypeLives() []*dot.TypeLives {
 	return lives
 }
 
+//GinNoblConfigTypeLives return config of ginNobl
+func GinNoblConfigTypeLives() *dot.ConfigTypeLives {
+	return &dot.ConfigTypeLives{
+		TypeIdConfig: GinNoblTypeId,
+		ConfigInfo:   &ginNoblConf{},
+	}
+}
+
+//Create make the cors policy, the invalid config is reported here
+func (c *ginNobl) Create(l dot.Line) error {
+	var err error
+	c.cors, err = newCorsPolicy(c.conf.Cors)
+	return err
+}
+
+//Start return the error if the gin router has no engine
+func (c *ginNobl) Start(ignore bool) error {
+	if c.GinRouter == nil || c.GinRouter.Router() == nil {
+		return dot.SError.NotExisted.AddNewError("the gin router of the ginNobl")
+	}
+	return nil
+}
+
 //Run after every component finished start, this can ensure all service has been registered on grpc server
 func (c *ginNobl) AfterAllStart(l dot.Line) {
 	if rp := c.GinRouter.RelativePath(); len(rp) > 0 && rp != "/" {
@@ -66,10 +121,9 @@ func (c *ginNobl) AfterAllStart(l dot.Line) {
 }
 
 //Stop stop dot
+//the routes of gin can not be removed, so reject the new requests, the running calls are stopped by the ServerNobl
 func (c *ginNobl) Stop(ignore bool) error {
-	if c.wrapserver != nil {
-		c.wrapserver = nil
-	}
+	atomic.StoreInt32(&c.draining, 1)
 	return nil
 }
 
@@ -80,7 +134,7 @@ func (c *ginNobl) Server() *grpc.Server {
 func (c *ginNobl) startServer() {
 
 	logger := dot.Logger()
-	c.wrapserver = grpcweb.WrapServer(c.Server(), grpcweb.WithAllowedRequestHeaders([]string{"Access-Control-Allow-Origin:*", "Access-Control-Allow-Methods:*"}))
+	c.wrapserver = grpcweb.WrapServer(c.Server())
 
 	url := c.preUrl
 	if len(url) > 0 {
@@ -91,7 +145,15 @@ func (c *ginNobl) startServer() {
 
 	handle := func(ctx *gin.Context) {
 		logger.Debugln("ginNobl", zap.String("", ctx.Request.RequestURI))
+		resp, done := c.cors.handle(ctx.Writer, ctx.Request)
+		if done {
+			return
+		}
 		if c.wrapserver.IsGrpcWebRequest(ctx.Request) {
+			if atomic.LoadInt32(&c.draining) == 1 || c.ServerNobl.Draining() {
+				rejectDraining(resp)
+				return
+			}
 
 			if len(c.preUrl) > 0 { // because can not set the "endpointFunc" of WrapServer, do this so so
 				old := ctx.Request.URL.Path
@@ -101,10 +163,6 @@ func (c *ginNobl) startServer() {
 				}
 			}
 
-			resp := ctx.Writer
-			resp.Header().Set("Access-Control-Allow-Origin", "*")  //
-			resp.Header().Set("Access-Control-Allow-Methods", "*") //
-			resp.Header().Add("Access-Control-Allow-Headers", "content-type,x-grpc-web,x-user-agent")
 			c.wrapserver.ServeHTTP(resp, ctx.Request)
 		} else {
 			ctx.String(http.StatusOK, "no rpc")
@@ -0,0 +1,32 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gserver
+
+import (
+	"testing"
+
+	"github.com/scryinfo/dot/dots/gindot"
+)
+
+//the router without the engine, the nobls do not start
+func TestNobl_NoRouter(t *testing.T) {
+	d, err := newGinNobl([]byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nobl := d.(*ginNobl)
+	nobl.GinRouter = &gindot.Router{}
+	if err = nobl.Start(false); err == nil {
+		t.Error("ginNobl starts without the router")
+	}
+
+	d, err = newGatewayNobl([]byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway := d.(*gatewayNobl)
+	if err = gateway.Start(false); err == nil {
+		t.Error("gatewayNobl starts without the addr and the router")
+	}
+}
@@ -0,0 +1,108 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gserver
+
+import (
+	"time"
+
+	"github.com/scryinfo/dot/dot"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+//ConfigHealth the grpc health service
+type ConfigHealth struct {
+	Enable   bool `json:"enable"`
+	Interval int  `json:"interval"` //second, check the status of the dots per interval, the default value is 5
+	//key: the service name, "" is the whole server; value: the live id of the dot that implements dot.Statuser or dot.Checker
+	//Statuser: 0 is SERVING, the others are NOT_SERVING
+	//Checker: the result of Check(nil), nil or true is SERVING, false or error is NOT_SERVING
+	Services map[string]dot.LiveId `json:"services"`
+}
+
+//feed the health server from the status of the dots
+type serverHealth struct {
+	conf   ConfigHealth
+	server *health.Server
+	dots   map[string]dot.Dot //key: service name
+	stop   chan struct{}
+}
+
+func newServerHealth(conf ConfigHealth, s *grpc.Server) *serverHealth {
+	c := &serverHealth{
+		conf:   conf,
+		server: health.NewServer(),
+		dots:   make(map[string]dot.Dot, len(conf.Services)),
+	}
+	grpc_health_v1.RegisterHealthServer(s, c.server)
+	return c
+}
+
+func (c *serverHealth) inject(l dot.Line) {
+	for name, lid := range c.conf.Services {
+		d, err := l.ToInjecter().GetByLiveId(lid)
+		if err != nil || d == nil {
+			dot.Logger().Errorln("serverHealth", zap.String("service", name), zap.String("", "can not find the dot: "+lid.String()), zap.Error(err))
+			continue
+		}
+		c.dots[name] = d
+	}
+}
+
+func (c *serverHealth) start() {
+	c.check()
+	interval := time.Duration(c.conf.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	c.stop = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				c.check()
+			}
+		}
+	}(c.stop)
+}
+
+func (c *serverHealth) check() {
+	for name, d := range c.dots {
+		c.server.SetServingStatus(name, statusOf(d))
+	}
+}
+
+//set all NOT_SERVING, and do not change them any more
+func (c *serverHealth) shutdown() {
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+	c.server.Shutdown()
+}
+
+func statusOf(d dot.Dot) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	serving := true
+	switch v := d.(type) {
+	case dot.Statuser:
+		serving = v.Status() == 0
+	case dot.Checker:
+		switch r := v.Check(nil).(type) {
+		case bool:
+			serving = r
+		case error:
+			serving = r == nil
+		}
+	}
+	if serving {
+		return grpc_health_v1.HealthCheckResponse_SERVING
+	}
+	return grpc_health_v1.HealthCheckResponse_NOT_SERVING
+}
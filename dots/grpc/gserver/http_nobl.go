@@ -6,17 +6,20 @@ package gserver
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
-	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/improbable-eng/grpc-web/go/grpcweb"
 	"github.com/pkg/errors"
 	"github.com/scryinfo/dot/dot"
 	"github.com/scryinfo/dot/dots/grpc/shared"
+	"github.com/scryinfo/dot/dots/tlsdot"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 const (
@@ -25,16 +28,22 @@ const (
 
 type httpNoblConf struct {
 	//sample :  1.1.1.1:568
-	PreUrl string           `json:"preUrl"`
-	Addr   string           `json:"addr"`
-	Tls    shared.TlsConfig `json:"tls"`
+	PreUrl      string           `json:"preUrl"`
+	Addr        string           `json:"addr"`
+	Tls         shared.TlsConfig `json:"tls"`
+	StopTimeout int              `json:"stopTimeout"` //second, see shutdownHttp
+	Cors        ConfigCors       `json:"cors"`
 }
 
 //support the http and tcp
 type httpNobl struct {
 	conf       httpNoblConf
-	ServerNobl ServerNobl `dot:""`
+	ServerNobl ServerNobl  `dot:""`
+	Tls        *tlsdot.Tls `dot:""` //the tls of the line, see tlsdot.Get
 	httpServer *http.Server
+	tlsConfig  *tls.Config //nil if no tls
+	cors       *corsPolicy
+	draining   int32 //atomic
 }
 
 //Construct component
@@ -96,20 +105,59 @@ func HttpNoblConfigTypeLives() *dot.ConfigTypeLives {
 	}
 }
 
+//Create make the tls config and cors policy, the invalid config is reported here
+func (c *httpNobl) Create(l dot.Line) error {
+	if c.Tls == nil {
+		c.Tls = tlsdot.Get(l)
+	}
+	var err error
+	if c.tlsConfig, err = c.Tls.ServerConfig(c.conf.Tls); err != nil {
+		return err
+	}
+	c.cors, err = newCorsPolicy(c.conf.Cors)
+	return err
+}
+
 //Run after every component finished start, this can ensure all service has been registered on grpc server
 func (c *httpNobl) AfterAllStart(l dot.Line) {
 	c.startServer()
 }
 
+//shutdownHttp wait for the running requests when stop, then close them after the timeout(second, the default value is 30)
+func shutdownHttp(s *http.Server, timeoutSec int, name string) {
+	timeout := time.Duration(timeoutSec) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		dot.Logger().Warnln(name, zap.String("", "graceful stop timeout, close the requests"), zap.Error(err))
+		_ = s.Close()
+	}
+}
+
 //Stop stop dot
 func (c *httpNobl) Stop(ignore bool) error {
+	atomic.StoreInt32(&c.draining, 1)
 	if c.httpServer != nil {
-		_ = c.httpServer.Shutdown(context.Background())
+		shutdownHttp(c.httpServer, c.conf.StopTimeout, "httpNobl")
 		c.httpServer = nil
 	}
 	return nil
 }
 
+func (c *httpNobl) isDraining() bool {
+	return atomic.LoadInt32(&c.draining) == 1 || c.ServerNobl.Draining()
+}
+
+//reject the new grpc-web request, when the server is stopping
+func rejectDraining(resp http.ResponseWriter) {
+	resp.Header().Set("grpc-status", strconv.Itoa(int(codes.Unavailable)))
+	resp.Header().Set("grpc-message", "the server is stopping")
+	resp.WriteHeader(http.StatusServiceUnavailable)
+}
+
 func (c *httpNobl) Server() *grpc.Server {
 	return c.ServerNobl.Server()
 }
@@ -117,16 +165,21 @@ func (c *httpNobl) Server() *grpc.Server {
 func (c *httpNobl) startServer() {
 	logger := dot.Logger()
 	//options.OptionsPassthrough
-	wrappedGrpc := grpcweb.WrapServer(c.Server(), grpcweb.WithAllowedRequestHeaders([]string{"Access-Control-Allow-Origin:*", "Access-Control-Allow-Methods:*"}))
+	wrappedGrpc := grpcweb.WrapServer(c.Server())
 
 	//start http grpc
 	c.httpServer = &http.Server{Addr: c.conf.Addr}
 	c.httpServer.Handler = http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
 		logger.Debugln("httpNobl", zap.String("", req.RequestURI))
 		//if wrappedGrpc.IsGrpcWebRequest(req) {
-		resp.Header().Set("Access-Control-Allow-Origin", "*")  //
-		resp.Header().Set("Access-Control-Allow-Methods", "*") //
-		resp.Header().Add("Access-Control-Allow-Headers", "content-type,x-grpc-web,x-user-agent")
+		resp, done := c.cors.handle(resp, req)
+		if done {
+			return
+		}
+		if c.isDraining() {
+			rejectDraining(resp)
+			return
+		}
 		if len(c.conf.PreUrl) > 0 { // because can not set the "endpointFunc" of WrapServer, do this so so
 			old := req.URL.Path
 			if strings.HasPrefix(old, c.conf.PreUrl) {
@@ -140,82 +193,18 @@ func (c *httpNobl) startServer() {
 	})
 
 	go func() {
-		switch {
-		case len(c.conf.Tls.CaPem) > 0 && len(c.conf.Tls.Key) > 0 && len(c.conf.Tls.Pem) > 0: //both tls
-			caPem := shared.GetFullPathFile(c.conf.Tls.CaPem)
-			if len(caPem) < 1 {
-				logger.Errorln("httpNobl", zap.Error(errors.New("the caPem is not empty, and can not find the file: "+c.conf.Tls.CaPem)))
-				return
-			}
-			key := shared.GetFullPathFile(c.conf.Tls.Key)
-			if len(key) < 1 {
-				logger.Errorln("httpNobl", zap.Error(errors.New("the Key is not empty, and can not find the file: "+c.conf.Tls.Key)))
-				return
-			}
-
-			pem := shared.GetFullPathFile(c.conf.Tls.Pem)
-			if len(pem) < 1 {
-				logger.Errorln("httpNobl", zap.Error(errors.New("the Pem is not empty, and can not find the file: "+c.conf.Tls.Pem)))
-				return
-			}
-
-			pool := x509.NewCertPool()
-			{
-				caCrt, err1 := ioutil.ReadFile(caPem)
-				if err1 != nil {
-					logger.Errorln("httpNobl", zap.Error(errors.WithStack(err1)))
-					return
-				}
-				if !pool.AppendCertsFromPEM(caCrt) {
-					logger.Errorln("httpNobl", zap.Error(errors.New("credentials: failed to append certificates")))
-					return
-				}
-			}
-			cert, err1 := tls.LoadX509KeyPair(pem, key)
-			if err1 != nil {
-				logger.Errorln("httpNobl", zap.Error(errors.WithStack(err1)))
-				return
-			}
-
-			c.httpServer.TLSConfig = &tls.Config{
-				Certificates: []tls.Certificate{cert},
-				ClientCAs:    pool,
-				ClientAuth:   tls.RequireAndVerifyClientCert,
-			}
-			logger.Infoln("httpNobl", zap.String("", "grpc-web server(with ca) will start: "+c.conf.Addr))
-			err := c.httpServer.ListenAndServeTLS("", "")
-			if err != nil {
-				logger.Errorln("httpNobl", zap.Error(errors.WithStack(err)))
-			}
-		case len(c.conf.Tls.Key) > 0 && len(c.conf.Tls.Pem) > 0: //just server
-			pem := shared.GetFullPathFile(c.conf.Tls.Pem)
-			if len(pem) < 1 {
-				logger.Errorln("httpNobl", zap.Error(errors.New("the pem is not empty, and can not find the file: "+c.conf.Tls.Pem)))
-				return
-			}
-			key := shared.GetFullPathFile(c.conf.Tls.Key)
-			if len(key) < 1 {
-				logger.Errorln("httpNobl", zap.Error(errors.New("the key is not empty, and can not find the file: "+c.conf.Tls.Key)))
-				return
-			}
-
-			c.httpServer.TLSConfig = &tls.Config{
-				ClientAuth: tls.NoClientCert,
-			}
-
-			logger.Infoln("httpNobl", zap.String("", "grpc-web server(no ca) will start: "+c.conf.Addr))
-			err := c.httpServer.ListenAndServeTLS(pem, key)
-			if err != nil {
-				logger.Errorln("httpNobl", zap.Error(errors.WithStack(err)))
-			}
-		default: //no tls
+		var err error
+		if c.tlsConfig != nil {
+			c.httpServer.TLSConfig = c.tlsConfig
+			logger.Infoln("httpNobl", zap.String("", "grpc-web server(https) will start: "+c.conf.Addr), zap.Bool("ca", len(c.conf.Tls.CaPem) > 0))
+			err = c.httpServer.ListenAndServeTLS("", "")
+		} else {
 			logger.Infoln("httpNobl", zap.String("", "grpc-web server(no https) will start: "+c.conf.Addr))
-			err := c.httpServer.ListenAndServe()
-			if err != nil {
-				logger.Errorln("httpNobl", zap.Error(errors.WithStack(err)))
-			}
+			err = c.httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logger.Errorln("httpNobl", zap.Error(errors.WithStack(err)))
 		}
-
 	}()
 
 }
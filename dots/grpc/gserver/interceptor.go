@@ -4,6 +4,8 @@
 package gserver
 
 import (
+	"sync"
+
 	"github.com/scryinfo/dot/dot"
 	"go.uber.org/zap"
 	"golang.org/x/net/context"
@@ -38,3 +40,94 @@ func StreamServerInterceptor() grpc.StreamServerInterceptor {
 		return handler(srv, stream)
 	}
 }
+
+//ServerInterceptor the named server interceptor, Unary or Stream can be nil
+type ServerInterceptor struct {
+	Unary  grpc.UnaryServerInterceptor
+	Stream grpc.StreamServerInterceptor
+}
+
+var (
+	serverInterceptors      = make(map[string]ServerInterceptor)
+	serverInterceptorsMutex sync.RWMutex
+)
+
+//RegisterServerInterceptor the dot register the interceptor, and then the server use it by the name in config "interceptors"
+//the names are resolved after all inject, so register it in Create or Injected of the dot
+func RegisterServerInterceptor(name string, interceptor ServerInterceptor) {
+	serverInterceptorsMutex.Lock()
+	defer serverInterceptorsMutex.Unlock()
+	serverInterceptors[name] = interceptor
+}
+
+//GetServerInterceptor return the registered interceptor
+func GetServerInterceptor(name string) (ServerInterceptor, bool) {
+	serverInterceptorsMutex.RLock()
+	defer serverInterceptorsMutex.RUnlock()
+	it, ok := serverInterceptors[name]
+	return it, ok
+}
+
+//the configured interceptors, they are resolved after the server is created
+//if one of them is not registered, all calls return the error, do not skip it(it may be the auth)
+type serverChain struct {
+	mutex  sync.RWMutex
+	unary  []grpc.UnaryServerInterceptor
+	stream []grpc.StreamServerInterceptor
+	err    error
+}
+
+func (c *serverChain) resolve(names []string) error {
+	var unary []grpc.UnaryServerInterceptor
+	var stream []grpc.StreamServerInterceptor
+	var err error
+	for _, name := range names {
+		it, ok := GetServerInterceptor(name)
+		if !ok {
+			err = status.Error(codes.Internal, "the server interceptor is not registered: "+name)
+			break
+		}
+		if it.Unary != nil {
+			unary = append(unary, it.Unary)
+		}
+		if it.Stream != nil {
+			stream = append(stream, it.Stream)
+		}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.unary, c.stream, c.err = unary, stream, err
+	return err
+}
+
+func (c *serverChain) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	c.mutex.RLock()
+	unary, err := c.unary, c.err
+	c.mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(unary) - 1; i >= 0; i-- { //the first one is the outermost
+		next, interceptor := handler, unary[i]
+		handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor(ctx, req, info, next)
+		}
+	}
+	return handler(ctx, req)
+}
+
+func (c *serverChain) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	c.mutex.RLock()
+	interceptors, err := c.stream, c.err
+	c.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		next, interceptor := handler, interceptors[i]
+		handler = func(srv interface{}, stream grpc.ServerStream) error {
+			return interceptor(srv, stream, info, next)
+		}
+	}
+	return handler(srv, stream)
+}
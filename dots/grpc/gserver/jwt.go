@@ -0,0 +1,191 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gserver
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"hash"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//verify the jwt of HS256, HS384, HS512, ES256, ES384 and ES512
+type jwtVerifier struct {
+	hmac     []byte
+	ecdsa    []*ecdsa.PublicKey
+	issuer   string
+	audience string
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+func jwtHash(alg string) (func() hash.Hash, crypto.Hash, error) {
+	switch alg[2:] {
+	case "256":
+		return sha256.New, crypto.SHA256, nil
+	case "384":
+		return sha512.New384, crypto.SHA384, nil
+	case "512":
+		return sha512.New, crypto.SHA512, nil
+	}
+	return nil, 0, errors.New("jwt: not supported alg: " + alg)
+}
+
+func (c *jwtVerifier) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("jwt: the token is invalid")
+	}
+	header := jwtHeader{}
+	if err := jwtDecode(parts[0], &header); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(header.Alg) != 5 {
+		return nil, errors.New("jwt: not supported alg: " + header.Alg)
+	}
+	newHash, cryptoHash, err := jwtHash(header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	ok := false
+	switch header.Alg[:2] {
+	case "HS":
+		if len(c.hmac) > 0 {
+			mac := hmac.New(newHash, c.hmac)
+			mac.Write(signed)
+			ok = hmac.Equal(sig, mac.Sum(nil))
+		}
+	case "ES":
+		h := cryptoHash.New()
+		h.Write(signed)
+		digest := h.Sum(nil)
+		for _, pub := range c.ecdsa {
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			if len(sig) == 2*size && ecdsa.Verify(pub, digest, new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])) {
+				ok = true
+				break
+			}
+		}
+	default:
+		return nil, errors.New("jwt: not supported alg: " + header.Alg)
+	}
+	if !ok {
+		return nil, errors.New("jwt: the signature is invalid")
+	}
+
+	claims := make(map[string]interface{})
+	if err = jwtDecode(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	now := float64(time.Now().Unix())
+	if exp, ok := claims["exp"].(float64); ok && now >= exp {
+		return nil, errors.New("jwt: the token is expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+		return nil, errors.New("jwt: the token is not valid yet")
+	}
+	if len(c.issuer) > 0 && claims["iss"] != c.issuer {
+		return nil, errors.New("jwt: the issuer is invalid")
+	}
+	if len(c.audience) > 0 && !jwtHasAudience(claims["aud"], c.audience) {
+		return nil, errors.New("jwt: the audience is invalid")
+	}
+	return claims, nil
+}
+
+//the "aud" is string or array of string
+func jwtHasAudience(aud interface{}, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, it := range v {
+			if it == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jwtDecode(part string, v interface{}) error {
+	bs, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(json.Unmarshal(bs, v))
+}
+
+//MakeJwt sign the claims, the key is []byte(HS256) or *ecdsa.PrivateKey(ES256, ES384 or ES512 by the curve)
+func MakeJwt(claims map[string]interface{}, key interface{}) (string, error) {
+	header := jwtHeader{Typ: "JWT"}
+	switch k := key.(type) {
+	case []byte:
+		header.Alg = "HS256"
+	case *ecdsa.PrivateKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			header.Alg = "ES256"
+		case 384:
+			header.Alg = "ES384"
+		case 521:
+			header.Alg = "ES512"
+		default:
+			return "", errors.New("jwt: not supported curve")
+		}
+	default:
+		return "", errors.New("jwt: not supported key")
+	}
+
+	hb, err := json.Marshal(header)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	cb, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(hb) + "." + base64.RawURLEncoding.EncodeToString(cb)
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *ecdsa.PrivateKey:
+		_, cryptoHash, _ := jwtHash(header.Alg)
+		h := cryptoHash.New()
+		h.Write([]byte(signed))
+		r, s, err := ecdsa.Sign(rand.Reader, k, h.Sum(nil))
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		rb, sb := r.Bytes(), s.Bytes()
+		copy(sig[size-len(rb):size], rb)
+		copy(sig[2*size-len(sb):], sb)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
@@ -0,0 +1,464 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/scryinfo/dot/dot"
+	"github.com/scryinfo/dot/dots/gindot"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	LimiterTypeId = "5d3c8a4e-1f2b-4c7d-a96e-0b8f7e2d4c13"
+
+	//the default name of the registered server interceptor and gin middleware
+	LimiterName = "limiter"
+
+	//the name of the in memory backend, it is the default backend
+	LimitBackendMemory = "memory"
+)
+
+type limiterConfig struct {
+	Name    string      `json:"name"`    //the name of the registered server interceptor and gin middleware, the default value is "limiter"
+	Backend string      `json:"backend"` //the name of the registered LimitBackend, the default value is "memory"
+	Rules   []limitRule `json:"rules"`   //all matched rules are checked
+	//the ips or cidrs of the proxies, the "ip" of http is the remote address, only if it is one of them, the "ip" is from "X-Forwarded-For"
+	TrustedProxies []string `json:"trustedProxies"`
+}
+
+type limitRule struct {
+	//the full method of grpc or the route path of gin, sample: "/pkg.Service/Method", "/pkg.Service/*", "/v1/users/:id" or "*"
+	Method string `json:"method"`
+	//the calls of the same key share the limits, join them by "+", sample: "method+ip"
+	//"method": the full method or the route path, "ip": the remote ip, "subject": the common name of the mtls client certificate,
+	//"token": the bearer token of "authorization", "md:name": the metadata(grpc) or the header(http) of the name
+	//empty means all matched calls share the limits
+	Key string `json:"key"`
+	//tokens per second of the bucket, 0 means no rate limit
+	Rate float64 `json:"rate"`
+	//the size of the bucket, the default value is the rate(at least 1)
+	Burst int `json:"burst"`
+	//the max running calls, 0 means no concurrency limit
+	Concurrency int `json:"concurrency"`
+}
+
+//LimitBackend store the token buckets and the running calls, the key contains the rule, so one backend can be shared by the limiters
+//the shared backend(sample: redis) registers it by RegisterLimitBackend, then the limiters use it by the name in config "backend"
+type LimitBackend interface {
+	//Take take one token from the bucket of the key, return false if there is no token
+	Take(key string, rate float64, burst int) (bool, error)
+	//Acquire add one running call of the key, return false if the max is reached
+	Acquire(key string, max int) (bool, error)
+	//Release remove one running call of the key, it is called after the Acquire returned true
+	Release(key string)
+}
+
+var (
+	limitBackends      = make(map[string]LimitBackend)
+	limitBackendsMutex sync.RWMutex
+)
+
+//RegisterLimitBackend the dot register the backend, the names are resolved after all inject, so register it in Create or Injected of the dot
+func RegisterLimitBackend(name string, backend LimitBackend) {
+	limitBackendsMutex.Lock()
+	defer limitBackendsMutex.Unlock()
+	limitBackends[name] = backend
+}
+
+//GetLimitBackend return the registered backend
+func GetLimitBackend(name string) (LimitBackend, bool) {
+	limitBackendsMutex.RLock()
+	defer limitBackendsMutex.RUnlock()
+	it, ok := limitBackends[name]
+	return it, ok
+}
+
+//Limiter limit the rate and the concurrency of the calls
+//the server interceptor(codes.ResourceExhausted) and the gin middleware(429) are registered in Create,
+//add the name to the "interceptors" of the server config or the "middlewares" of the gin router config
+//if the backend returns an error, the call is allowed
+type Limiter struct {
+	conf    limiterConfig
+	backend LimitBackend
+	proxies []*net.IPNet //the trusted proxies
+}
+
+func newLimiter(conf interface{}) (dot.Dot, error) {
+	var err error = nil
+	var bs []byte = nil
+	if bt, ok := conf.([]byte); ok {
+		bs = bt
+	} else {
+		return nil, dot.SError.Parameter
+	}
+	dconf := &limiterConfig{}
+	err = dot.UnMarshalConfig(bs, dconf)
+	if err != nil {
+		return nil, err
+	}
+	if len(dconf.Name) < 1 {
+		dconf.Name = LimiterName
+	}
+	if len(dconf.Backend) < 1 {
+		dconf.Backend = LimitBackendMemory
+	}
+	for i := range dconf.Rules {
+		if r := &dconf.Rules[i]; r.Burst < 1 {
+			r.Burst = int(r.Rate)
+			if r.Burst < 1 {
+				r.Burst = 1
+			}
+		}
+	}
+
+	d := &Limiter{
+		conf: *dconf,
+	}
+	for _, it := range dconf.TrustedProxies {
+		if !strings.Contains(it, "/") {
+			if ip := net.ParseIP(it); ip != nil && ip.To4() != nil {
+				it += "/32"
+			} else {
+				it += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(it)
+		if err != nil {
+			return nil, err
+		}
+		d.proxies = append(d.proxies, ipNet)
+	}
+
+	return d, err
+}
+
+//LimiterTypeLives Data structure needed when generating newer component
+func LimiterTypeLives() *dot.TypeLives {
+	return &dot.TypeLives{
+		Meta: dot.Metadata{TypeId: LimiterTypeId, NewDoter: func(conf interface{}) (dot dot.Dot, err error) {
+			return newLimiter(conf)
+		}},
+	}
+}
+
+//LimiterConfigTypeLives return config of Limiter
+func LimiterConfigTypeLives() *dot.ConfigTypeLives {
+	return &dot.ConfigTypeLives{
+		TypeIdConfig: LimiterTypeId,
+		ConfigInfo: &limiterConfig{
+			Rules: []limitRule{{Method: "*", Key: "ip", Rate: 100, Burst: 200}},
+		},
+	}
+}
+
+func (c *Limiter) Create(l dot.Line) error {
+	if c.conf.Backend == LimitBackendMemory {
+		c.backend = newMemoryBackend()
+	}
+	RegisterServerInterceptor(c.conf.Name, ServerInterceptor{Unary: c.UnaryInterceptor, Stream: c.StreamInterceptor})
+	gindot.RegisterMiddleware(c.conf.Name, c.Middleware)
+	return nil
+}
+
+//AfterAllInject resolve the shared backend, the dots have registered it
+func (c *Limiter) AfterAllInject(l dot.Line) {
+	if c.backend != nil {
+		return
+	}
+	if b, ok := GetLimitBackend(c.conf.Backend); ok {
+		c.backend = b
+	} else {
+		dot.Logger().Errorln("Limiter", zap.String("", "the backend is not registered, use the memory"), zap.String("backend", c.conf.Backend))
+		c.backend = newMemoryBackend()
+	}
+}
+
+//UnaryInterceptor limit the unary call
+func (c *Limiter) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	release, err := c.Limit(info.FullMethod, grpcLimitKeyer(ctx))
+	if err != nil {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	defer release()
+	return handler(ctx, req)
+}
+
+//StreamInterceptor limit the stream call, the running stream holds the concurrency until it ends
+func (c *Limiter) StreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	release, err := c.Limit(info.FullMethod, grpcLimitKeyer(stream.Context()))
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	defer release()
+	return handler(srv, stream)
+}
+
+//Middleware limit the http request of gin, the rules match the route path(sample: "/v1/users/:id") of the gindot.Engine
+func (c *Limiter) Middleware(ctx *gin.Context) {
+	release, err := c.Limit(gindot.FullPath(ctx), c.httpLimitKeyer(ctx))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"code": codes.ResourceExhausted, "message": err.Error()})
+		return
+	}
+	defer release()
+	ctx.Next()
+}
+
+//Limit check the rules of the method, the keyer returns the value of the key part("ip", "subject", "token" and "md:name")
+//return the release of the concurrency if the call is allowed
+func (c *Limiter) Limit(method string, keyer func(part string) string) (func(), error) {
+	var acquired []string
+	release := func() {
+		for _, key := range acquired {
+			c.backend.Release(key)
+		}
+	}
+	for i := range c.conf.Rules {
+		r := &c.conf.Rules[i]
+		if !r.match(method) || (r.Rate <= 0 && r.Concurrency <= 0) {
+			continue
+		}
+		key := r.key(c.conf.Name+"#"+strconv.Itoa(i), method, keyer)
+		if r.Rate > 0 {
+			if ok, err := c.backend.Take(key, r.Rate, r.Burst); err != nil {
+				dot.Logger().Warnln("Limiter", zap.String("key", key), zap.Error(err))
+			} else if !ok {
+				release()
+				return nil, errors.New("the rate limit is exceeded: " + r.Method)
+			}
+		}
+		if r.Concurrency > 0 {
+			if ok, err := c.backend.Acquire(key, r.Concurrency); err != nil {
+				dot.Logger().Warnln("Limiter", zap.String("key", key), zap.Error(err))
+			} else if !ok {
+				release()
+				return nil, errors.New("the concurrency limit is exceeded: " + r.Method)
+			} else {
+				acquired = append(acquired, key) //release only the acquired
+			}
+		}
+	}
+	return release, nil
+}
+
+func (c *limitRule) match(method string) bool {
+	switch {
+	case c.Method == "*" || c.Method == method:
+		return true
+	case strings.HasSuffix(c.Method, "/*") && strings.HasPrefix(method, c.Method[:len(c.Method)-1]):
+		return true
+	}
+	return false
+}
+
+//the key of the backend: the name of the limiter and the index of the rule, then the values of the key parts
+func (c *limitRule) key(prefix string, method string, keyer func(part string) string) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	for _, part := range strings.Split(c.Key, "+") {
+		b.WriteByte('|')
+		if part == "method" {
+			b.WriteString(method)
+		} else {
+			b.WriteString(keyer(part))
+		}
+	}
+	return b.String()
+}
+
+//do not put the token into the key, it may be stored in the shared backend
+func hashToken(token string) string {
+	if len(token) < 1 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+func bearerToken(authorization string) string {
+	const bearer = "bearer "
+	if len(authorization) > len(bearer) && strings.EqualFold(authorization[:len(bearer)], bearer) {
+		return strings.TrimSpace(authorization[len(bearer):])
+	}
+	return ""
+}
+
+func grpcLimitKeyer(ctx context.Context) func(part string) string {
+	return func(part string) string {
+		switch {
+		case part == "ip":
+			if pr, ok := peer.FromContext(ctx); ok && pr.Addr != nil {
+				if host, _, err := net.SplitHostPort(pr.Addr.String()); err == nil {
+					return host
+				}
+				return pr.Addr.String()
+			}
+		case part == "subject":
+			if pr, ok := peer.FromContext(ctx); ok {
+				if info, ok := pr.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 && len(info.State.VerifiedChains[0]) > 0 {
+					return info.State.VerifiedChains[0][0].Subject.CommonName
+				}
+			}
+		case part == "token":
+			if md, ok := metadata.FromIncomingContext(ctx); ok {
+				if v := md.Get("authorization"); len(v) > 0 {
+					return hashToken(bearerToken(v[0]))
+				}
+			}
+		case strings.HasPrefix(part, "md:"):
+			if md, ok := metadata.FromIncomingContext(ctx); ok {
+				return strings.Join(md.Get(part[len("md:"):]), ",")
+			}
+		}
+		return ""
+	}
+}
+
+func (c *Limiter) httpLimitKeyer(ctx *gin.Context) func(part string) string {
+	return func(part string) string {
+		switch {
+		case part == "ip":
+			return c.clientIp(ctx.Request)
+		case part == "subject":
+			if s := ctx.Request.TLS; s != nil && len(s.VerifiedChains) > 0 && len(s.VerifiedChains[0]) > 0 {
+				return s.VerifiedChains[0][0].Subject.CommonName
+			}
+		case part == "token":
+			return hashToken(bearerToken(ctx.GetHeader("Authorization")))
+		case strings.HasPrefix(part, "md:"):
+			return strings.Join(ctx.Request.Header[http.CanonicalHeaderKey(part[len("md:"):])], ",")
+		}
+		return ""
+	}
+}
+
+//the remote ip, if it is a trusted proxy, the rightmost ip of "X-Forwarded-For" that is not a trusted proxy
+//do not use the gin ClientIP, the client sets any "X-Forwarded-For" and gets a new bucket
+func (c *Limiter) clientIp(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !c.trusted(ip) {
+		return ip
+	}
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		if it := strings.TrimSpace(forwarded[i]); len(it) > 0 {
+			ip = it
+			if !c.trusted(it) {
+				break
+			}
+		}
+	}
+	return ip
+}
+
+func (c *Limiter) trusted(ip string) bool {
+	if len(c.proxies) < 1 {
+		return false
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, it := range c.proxies {
+		if it.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+//the in memory backend, the full buckets are removed every minute
+type memoryBackend struct {
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+	running map[string]int
+	swept   time.Time
+	now     func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time //the bucket is full after it
+}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{
+		buckets: make(map[string]*tokenBucket),
+		running: make(map[string]int),
+		now:     time.Now,
+	}
+}
+
+func (c *memoryBackend) Take(key string, rate float64, burst int) (bool, error) {
+	now := c.now()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if now.Sub(c.swept) > time.Minute {
+		for k, it := range c.buckets {
+			if now.After(it.full) {
+				delete(c.buckets, k)
+			}
+		}
+		c.swept = now
+	}
+
+	b, ok := c.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		c.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false, nil
+	}
+	b.tokens--
+	b.full = now.Add(time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second)))
+	return true, nil
+}
+
+func (c *memoryBackend) Acquire(key string, max int) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.running[key] >= max {
+		return false, nil
+	}
+	c.running[key]++
+	return true, nil
+}
+
+func (c *memoryBackend) Release(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if n := c.running[key] - 1; n > 0 {
+		c.running[key] = n
+	} else {
+		delete(c.running, key)
+	}
+}
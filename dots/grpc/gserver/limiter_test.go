@@ -0,0 +1,214 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scryinfo/dot/dots/gindot"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func newTestLimiter(t *testing.T, conf string) *Limiter {
+	d, err := newLimiter([]byte(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := d.(*Limiter)
+	if err = c.Create(nil); err != nil {
+		t.Fatal(err)
+	}
+	c.AfterAllInject(nil)
+	return c
+}
+
+func TestLimiter_Limit(t *testing.T) {
+	c := newTestLimiter(t, `{"name":"limitTest","rules":[
+		{"method":"/pkg.S/*","key":"method+md:user","rate":0.001,"burst":2},
+		{"method":"/pkg.S/Run","concurrency":1}]}`)
+	keyer := func(user string) func(string) string {
+		return func(part string) string {
+			if part == "md:user" {
+				return user
+			}
+			return ""
+		}
+	}
+	allow := func(method string, user string) bool {
+		release, err := c.Limit(method, keyer(user))
+		if err == nil {
+			release()
+		}
+		return err == nil
+	}
+
+	//the bucket of every method and user
+	for i, want := range []bool{true, true, false} {
+		if allow("/pkg.S/A", "a") != want {
+			t.Error(i, want)
+		}
+	}
+	if !allow("/pkg.S/A", "b") || !allow("/pkg.S/B", "a") || !allow("/none", "a") {
+		t.Error("the other key is limited")
+	}
+
+	//the concurrency, the rejected call does not hold it
+	release, err := c.Limit("/pkg.S/Run", keyer("c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Limit("/pkg.S/Run", keyer("d")); err == nil {
+		t.Error("the concurrency is not limited")
+	}
+	release()
+	if !allow("/pkg.S/Run", "d") {
+		t.Error("the concurrency is not released")
+	}
+	if allow("/pkg.S/Run", "d") {
+		t.Error("the rate is not limited")
+	}
+}
+
+func TestLimiter_MemoryBackend(t *testing.T) {
+	now := time.Now()
+	b := newMemoryBackend()
+	b.now = func() time.Time { return now }
+	take := func() bool {
+		ok, _ := b.Take("k", 2, 2)
+		return ok
+	}
+	if !take() || !take() || take() {
+		t.Error("the burst")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if !take() || take() {
+		t.Error("the refill")
+	}
+	now = now.Add(2 * time.Minute)
+	_, _ = b.Take("other", 2, 2)
+	if _, ok := b.buckets["k"]; ok || len(b.buckets) != 1 {
+		t.Error("the full bucket is not removed")
+	}
+}
+
+func TestLimiter_Server(t *testing.T) {
+	newTestLimiter(t, `{"name":"limitServer","rules":[{"method":"/grpc.health.v1.Health/Check","key":"token","rate":0.001,"burst":1}]}`)
+	_, client, stop := startTestServer(t, `{"addrs":["127.0.0.1:0"],"interceptors":["limitServer"]}`)
+	defer stop()
+
+	check := func(token string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+		_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		return err
+	}
+	if err := check("a"); err != nil {
+		t.Error(err)
+	}
+	if err := check("a"); status.Code(err) != codes.ResourceExhausted {
+		t.Error(err)
+	}
+	if err := check("b"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	c := newTestLimiter(t, `{"name":"limitGin","rules":[{"method":"/v1/*","key":"method+ip","rate":0.001,"burst":1}]}`)
+	d, err := gindot.TypeLiveGinDot().Meta.NewDoter([]byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := d.(*gindot.Engine)
+	if err = e.Create(nil); err != nil {
+		t.Fatal(err)
+	}
+	e.GinEngine().GET("/v1/users/:id", c.Middleware, func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "hi")
+	})
+	request := func(path string, ip string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("X-Forwarded-For", path) //a new value, it is not the ip
+		rec := httptest.NewRecorder()
+		e.GinEngine().ServeHTTP(rec, req)
+		return rec.Code
+	}
+	for i, it := range []struct {
+		path, ip string
+		code     int
+	}{
+		{"/v1/users/1", "1.1.1.1", http.StatusOK},
+		{"/v1/users/2", "1.1.1.1", http.StatusTooManyRequests}, //the same route
+		{"/v1/users/1", "2.2.2.2", http.StatusOK},
+	} {
+		if code := request(it.path, it.ip); code != it.code {
+			t.Error(i, code)
+		}
+	}
+}
+
+func TestLimiter_TrustedProxies(t *testing.T) {
+	c := newTestLimiter(t, `{"name":"limitProxy","trustedProxies":["10.0.0.0/8","192.168.1.1"],"rules":[{"method":"*","key":"ip","rate":1}]}`)
+	for i, it := range []struct {
+		remote, forwarded, ip string
+	}{
+		{"1.1.1.1:1", "2.2.2.2", "1.1.1.1"},                        //not a proxy
+		{"10.1.1.1:1", "2.2.2.2", "2.2.2.2"},                       //the proxy
+		{"192.168.1.1:1", "3.3.3.3, 2.2.2.2, 10.0.0.1", "2.2.2.2"}, //the client sets 3.3.3.3
+		{"10.1.1.1:1", "", "10.1.1.1"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = it.remote
+		req.Header.Set("X-Forwarded-For", it.forwarded)
+		if ip := c.clientIp(req); ip != it.ip {
+			t.Error(i, ip)
+		}
+	}
+	if _, err := newLimiter([]byte(`{"trustedProxies":["none"]}`)); err == nil {
+		t.Error("the wrong proxy is accepted")
+	}
+}
+
+//the backend fails, the calls are allowed and nothing is released
+type testErrorBackend struct {
+	released int32
+}
+
+func (c *testErrorBackend) Take(key string, rate float64, burst int) (bool, error) {
+	return false, errors.New("test")
+}
+
+func (c *testErrorBackend) Acquire(key string, max int) (bool, error) {
+	return false, errors.New("test")
+}
+
+func (c *testErrorBackend) Release(key string) {
+	atomic.AddInt32(&c.released, 1)
+}
+
+func TestLimiter_BackendError(t *testing.T) {
+	b := &testErrorBackend{}
+	RegisterLimitBackend("testError", b)
+	c := newTestLimiter(t, `{"name":"limitError","backend":"testError","rules":[{"method":"*","rate":1,"concurrency":1}]}`)
+	for i := 0; i < 3; i++ {
+		release, err := c.Limit("/pkg.S/A", func(string) string { return "" })
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	if n := atomic.LoadInt32(&b.released); n != 0 {
+		t.Error(n)
+	}
+}
@@ -0,0 +1,212 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gserver
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	"github.com/pkg/errors"
+	"github.com/scryinfo/dot/dot"
+	"github.com/scryinfo/dot/dots/gindot"
+	"github.com/scryinfo/dot/dots/grpc/shared"
+	"github.com/scryinfo/dot/dots/tlsdot"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+)
+
+const (
+	MuxNoblTypeId = "15f25c5c-19b5-440f-99a8-b3ea2ccd335e"
+)
+
+type muxNoblConf struct {
+	//sample :  1.1.1.1:568
+	Addr string `json:"addr"`
+	//the prefix of the grpc-web url, sample: "/rpc/"
+	PreUrl string `json:"preUrl"`
+	//if it is none, the http/2 is h2c(http/2 without tls)
+	Tls         shared.TlsConfig `json:"tls"`
+	StopTimeout int              `json:"stopTimeout"` //second, see shutdownHttp
+	Cors        ConfigCors       `json:"cors"`        //the cors policy of the grpc-web
+}
+
+//one listener for the grpc, grpc-web and gin:
+//http/2 with the content type "application/grpc" to the grpc server,
+//the grpc-web(and the cors of it) to the grpcweb wrapper,
+//the others to the gin engine, set the "noListen" of the gin engine to true
+type muxNobl struct {
+	conf       muxNoblConf
+	ServerNobl ServerNobl     `dot:""`
+	GinEngine  *gindot.Engine `dot:""`
+	Tls        *tlsdot.Tls    `dot:""` //the tls of the line, see tlsdot.Get
+	listener   net.Listener
+	tlsConfig  *tls.Config //nil if no tls
+	cors       *corsPolicy
+	httpServer *http.Server
+	wrapped    *grpcweb.WrappedGrpcServer
+	draining   int32 //atomic
+}
+
+//Construct component
+func newMuxNobl(conf interface{}) (dot.Dot, error) {
+	var err error = nil
+	var bs []byte = nil
+	if bt, ok := conf.([]byte); ok {
+		bs = bt
+	} else {
+		return nil, dot.SError.Parameter
+	}
+	dconf := &muxNoblConf{}
+	err = dot.UnMarshalConfig(bs, dconf)
+	if err != nil {
+		return nil, err
+	}
+	if len(dconf.PreUrl) > 0 {
+		if !strings.HasPrefix(dconf.PreUrl, "/") {
+			dconf.PreUrl = "/" + dconf.PreUrl
+		}
+		if !strings.HasSuffix(dconf.PreUrl, "/") {
+			dconf.PreUrl += "/"
+		}
+	}
+
+	d := &muxNobl{
+		conf: *dconf,
+	}
+
+	return d, err
+}
+
+//MuxNoblTypeLives Data structure needed when generating newer component, include the ServerNobl and gin engine
+func MuxNoblTypeLives() []*dot.TypeLives {
+	tl := &dot.TypeLives{
+		Meta: dot.Metadata{TypeId: MuxNoblTypeId, NewDoter: func(conf interface{}) (dot dot.Dot, err error) {
+			return newMuxNobl(conf)
+		}},
+		Lives: []dot.Live{
+			{
+				LiveId:    MuxNoblTypeId,
+				RelyLives: map[string]dot.LiveId{"ServerNobl": ServerNoblTypeId, "GinEngine": gindot.EngineLiveId},
+			},
+		},
+	}
+
+	return []*dot.TypeLives{
+		tl, ServerNoblTypeLive(), gindot.TypeLiveGinDot(),
+	}
+}
+
+//MuxNoblConfigTypeLives return config of muxNobl
+func MuxNoblConfigTypeLives() *dot.ConfigTypeLives {
+	return &dot.ConfigTypeLives{
+		TypeIdConfig: MuxNoblTypeId,
+		ConfigInfo:   &muxNoblConf{},
+	}
+}
+
+//Create listen the addr and make the tls config and cors policy, so the errors of them are reported here
+func (c *muxNobl) Create(l dot.Line) error {
+	if c.Tls == nil {
+		c.Tls = tlsdot.Get(l)
+	}
+	var err error
+	if c.tlsConfig, err = c.Tls.ServerConfig(c.conf.Tls); err != nil {
+		return err
+	}
+	if c.cors, err = newCorsPolicy(c.conf.Cors); err != nil {
+		return err
+	}
+	if c.listener, err = net.Listen("tcp", c.conf.Addr); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+//Run after every component finished start, this can ensure all service has been registered on grpc server
+func (c *muxNobl) AfterAllStart(l dot.Line) {
+	c.startServer()
+}
+
+//Stop stop dot
+func (c *muxNobl) Stop(ignore bool) error {
+	atomic.StoreInt32(&c.draining, 1)
+	if c.httpServer != nil {
+		shutdownHttp(c.httpServer, c.conf.StopTimeout, "muxNobl")
+		c.httpServer = nil
+	} else if c.listener != nil {
+		_ = c.listener.Close()
+	}
+	c.listener = nil
+	return nil
+}
+
+func (c *muxNobl) Server() *grpc.Server {
+	return c.ServerNobl.Server()
+}
+
+//Addr return the address of the listener, it is useful when the port of config is 0
+func (c *muxNobl) Addr() net.Addr {
+	if c.listener == nil {
+		return nil
+	}
+	return c.listener.Addr()
+}
+
+func (c *muxNobl) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	switch {
+	case req.ProtoMajor == 2 && strings.HasPrefix(req.Header.Get("content-type"), "application/grpc") && !c.wrapped.IsGrpcWebRequest(req):
+		c.Server().ServeHTTP(resp, req)
+	case c.wrapped.IsGrpcWebRequest(req) || c.wrapped.IsAcceptableGrpcCorsRequest(req):
+		resp, done := c.cors.handle(resp, req)
+		if done {
+			return
+		}
+		if atomic.LoadInt32(&c.draining) == 1 || c.ServerNobl.Draining() {
+			rejectDraining(resp)
+			return
+		}
+		if len(c.conf.PreUrl) > 0 { // because can not set the "endpointFunc" of WrapServer, do this so so
+			if old := req.URL.Path; strings.HasPrefix(old, c.conf.PreUrl) {
+				req.URL.Path = old[len(c.conf.PreUrl)-1:]
+			}
+		}
+		c.wrapped.ServeHTTP(resp, req)
+	case c.GinEngine != nil && c.GinEngine.GinEngine() != nil:
+		c.GinEngine.GinEngine().ServeHTTP(resp, req)
+	default:
+		http.NotFound(resp, req)
+	}
+}
+
+func (c *muxNobl) startServer() {
+	logger := dot.Logger()
+	c.wrapped = grpcweb.WrapServer(c.Server())
+	c.httpServer = &http.Server{Handler: c}
+
+	h2 := &http2.Server{}
+	lis := c.listener
+	if c.tlsConfig != nil {
+		c.httpServer.TLSConfig = c.tlsConfig
+		if err := http2.ConfigureServer(c.httpServer, h2); err != nil {
+			logger.Errorln("muxNobl", zap.Error(errors.WithStack(err)))
+			return
+		}
+		lis = tls.NewListener(lis, c.httpServer.TLSConfig)
+	} else {
+		c.httpServer.Handler = h2c.NewHandler(c, h2)
+	}
+
+	go func(s *http.Server) {
+		logger.Infoln("muxNobl", zap.String("", "grpc, grpc-web and gin will start: "+lis.Addr().String()), zap.Bool("tls", c.tlsConfig != nil))
+		if err := s.Serve(lis); err != nil && err != http.ErrServerClosed {
+			logger.Errorln("muxNobl", zap.Error(errors.WithStack(err)))
+		}
+	}(c.httpServer)
+}
@@ -0,0 +1,138 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scryinfo/dot/dots/certificate"
+	"github.com/scryinfo/dot/dots/gindot"
+	"github.com/scryinfo/dot/dots/grpc/shared"
+	"github.com/scryinfo/dot/dots/tlsdot"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestMuxNobl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ec := &certificate.Ecdsa{}
+	caPri, _ := certificate.MakePriKey()
+	ca, err := ec.GenerateCaCertKey(caPri, filepath.Join(dir, "ca.key"), filepath.Join(dir, "ca.pem"), []string{"scry"}, []string{"scry"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pem, key := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+	if err = ec.GenerateCertKey(ca, caPri, key, pem, []string{"server.scry"}, []string{"scry"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tlsConf := range []shared.TlsConfig{{}, {Mode: shared.TlsModeServer, Pem: pem, Key: key}} {
+		d, err := newServerNobl([]byte(`{"addrs":[]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := d.(*serverNoblImp)
+		if err = server.Create(nil); err != nil {
+			t.Fatal(err)
+		}
+		grpc_health_v1.RegisterHealthServer(server.Server(), health.NewServer())
+
+		d, err = gindot.TypeLiveGinDot().Meta.NewDoter([]byte(`{"noListen":true}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine := d.(*gindot.Engine)
+		if err = engine.Create(nil); err != nil {
+			t.Fatal(err)
+		}
+		engine.GinEngine().GET("/hi", func(ctx *gin.Context) {
+			ctx.String(http.StatusOK, "hi")
+		})
+
+		d, err = newMuxNobl([]byte(`{"addr":"127.0.0.1:0"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mux := d.(*muxNobl)
+		mux.conf.Tls = tlsConf
+		mux.ServerNobl = server
+		mux.GinEngine = engine
+		if err = mux.Create(nil); err != nil {
+			t.Fatal(err)
+		}
+		mux.AfterAllStart(nil)
+
+		addr := mux.Addr().String()
+		scheme := "http://"
+		dialOpt := grpc.WithInsecure()
+		httpClient := &http.Client{}
+		if len(tlsConf.Pem) > 0 {
+			scheme = "https://"
+			creds, err := tlsdot.New().ClientCredentials(shared.TlsConfig{Pem: filepath.Join(dir, "ca.pem"), ServerNameOverride: "server.scry"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			dialOpt = grpc.WithTransportCredentials(creds)
+			caCert, err := ec.Certificate(filepath.Join(dir, "ca.pem"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pool := x509.NewCertPool()
+			pool.AddCert(caCert)
+			httpClient.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, ServerName: "server.scry"}}
+		}
+
+		//grpc
+		cc, err := grpc.Dial(addr, dialOpt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res, err := grpc_health_v1.NewHealthClient(cc).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}); err != nil || res.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+			t.Error(tlsConf.Mode, res, err)
+		}
+		_ = cc.Close()
+
+		//grpc-web, the empty request message
+		req, _ := http.NewRequest(http.MethodPost, scheme+addr+"/grpc.health.v1.Health/Check", bytes.NewReader([]byte{0, 0, 0, 0, 0}))
+		req.Header.Set("content-type", "application/grpc-web+proto")
+		if resp, err := httpClient.Do(req); err != nil {
+			t.Error(tlsConf.Mode, err)
+		} else {
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("content-type"), "application/grpc-web") {
+				t.Error(tlsConf.Mode, resp.StatusCode, resp.Header)
+			}
+		}
+
+		//gin
+		if resp, err := httpClient.Get(scheme + addr + "/hi"); err != nil {
+			t.Error(tlsConf.Mode, err)
+		} else {
+			body, _ := ioutil.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if string(body) != "hi" {
+				t.Error(tlsConf.Mode, resp.StatusCode, string(body))
+			}
+		}
+
+		httpClient.CloseIdleConnections() //the transport may dial the spare connection, the Shutdown waits for the new connection
+		_ = mux.Stop(false)
+		_ = server.Stop(false)
+	}
+}
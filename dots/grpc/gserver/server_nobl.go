@@ -4,16 +4,21 @@
 package gserver
 
 import (
-	"crypto/tls"
-	"crypto/x509"
+	"context"
 	"github.com/pkg/errors"
 	"github.com/scryinfo/dot/dot"
 	"github.com/scryinfo/dot/dots/grpc/shared"
+	"github.com/scryinfo/dot/dots/tlsdot"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
-	"io/ioutil"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 	"net"
+	"sort"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -22,13 +27,57 @@ const (
 
 type ServerNobl interface {
 	Server() *grpc.Server
+	//Health return the health server, nil if it is disabled, the dots can set the status of their services
+	Health() *health.Server
+	//Draining return true if the server is stopping, the new calls should be rejected
+	Draining() bool
+	//MaxRecvMsgSize return the max size(byte) of the received message
+	MaxRecvMsgSize() int
+}
+
+//GrpcRegister the dot registers its grpc services, the ServerNobl calls it for the live dots of the line after all inject,
+//if it returns an error or panics, the server does not start
+//if there are more than one ServerNobl in the line, set ConfigNobl.Register, grpc exits when a service is registered twice
+type GrpcRegister interface {
+	RegisterGrpc(s *grpc.Server) error
 }
 
 type ConfigNobl struct {
-	//sample :  1.1.1.1:568
+	//sample :  1.1.1.1:568, or "inproc://name" for the clients in the same process(no socket)
 	Addrs []string `json:"addrs"`
 
 	Tls shared.TlsConfig `json:"tls"`
+
+	//the names of the server interceptors in order, they are called after the panic recovery, see RegisterServerInterceptor
+	Interceptors []string `json:"interceptors"`
+
+	MaxConcurrentStreams uint32          `json:"maxConcurrentStreams"` //0 means the default of grpc
+	MaxRecvMsgSize       int             `json:"maxRecvMsgSize"`       //byte, 0 means the default of grpc
+	MaxSendMsgSize       int             `json:"maxSendMsgSize"`       //byte, 0 means the default of grpc
+	ConnectionTimeout    int             `json:"connectionTimeout"`    //second, the timeout of the connection setup(include tls handshake), 0 means the default of grpc
+	Keepalive            ConfigKeepalive `json:"keepalive"`
+
+	Health     ConfigHealth `json:"health"`     //register the grpc health service
+	Reflection bool         `json:"reflection"` //register the grpc reflection service, for grpcurl
+
+	//the live ids of the dots that register the grpc services(see GrpcRegister), empty means all live dots of the line
+	Register []dot.LiveId `json:"register"`
+
+	//second, wait for the running calls when stop, then stop the server and cut off the calls, the default value is 30
+	StopTimeout int `json:"stopTimeout"`
+}
+
+//ConfigKeepalive the keepalive of the server, all are second, 0 means the default of grpc
+type ConfigKeepalive struct {
+	MaxConnectionIdle     int `json:"maxConnectionIdle"`
+	MaxConnectionAge      int `json:"maxConnectionAge"`
+	MaxConnectionAgeGrace int `json:"maxConnectionAgeGrace"`
+	Time                  int `json:"time"`    //ping the client after the time of no activity
+	Timeout               int `json:"timeout"` //wait for the ping ack
+
+	//enforcement policy, the client ping more frequently than MinTime will be closed
+	MinTime             int  `json:"minTime"`
+	PermitWithoutStream bool `json:"permitWithoutStream"`
 }
 
 //grpc server component, without bl; one server can monitor in multi address or API at the same time,support tls
@@ -36,6 +85,13 @@ type serverNoblImp struct {
 	conf      ConfigNobl
 	server    *grpc.Server
 	listeners []net.Listener
+	chain     serverChain
+	health    *serverHealth
+	regErr    error //the error of the GrpcRegister, the server does not start
+	active    int64 //atomic, the running calls
+	draining  int32 //atomic
+
+	Tls *tlsdot.Tls `dot:""` //the tls of the line, see tlsdot.Get
 }
 
 //Construct component
@@ -83,6 +139,9 @@ func ServerNoblConfigTypeLive() *dot.ConfigTypeLives {
 }
 
 func (c *serverNoblImp) Create(l dot.Line) error {
+	if c.Tls == nil {
+		c.Tls = tlsdot.Get(l)
+	}
 	logger := dot.Logger()
 	var err error = nil
 	errDo := func(er error) {
@@ -96,7 +155,7 @@ func (c *serverNoblImp) Create(l dot.Line) error {
 		for i := range c.conf.Addrs {
 			addr := c.conf.Addrs[i]
 			var err2 error = nil
-			lis, err2 := net.Listen("tcp", addr)
+			lis, err2 := shared.Listen(addr) //tcp or inproc
 			if err2 != nil {
 				if err != nil {
 					dot.Logger().Errorln(err.Error())
@@ -108,112 +167,208 @@ func (c *serverNoblImp) Create(l dot.Line) error {
 		}
 	}
 	//see https://bbengfort.github.io/programmer/2017/03/03/secure-grpc.html
-	switch {
-	case len(c.conf.Tls.CaPem) > 0 && len(c.conf.Tls.Pem) > 0 && len(c.conf.Tls.Key) > 0:
-		pem := shared.GetFullPathFile(c.conf.Tls.Pem)
-		if len(pem) < 1 {
-			errDo(errors.New("the pem is not empty, and can not find the file: " + c.conf.Tls.Pem))
-			return err
-		}
-		key := shared.GetFullPathFile(c.conf.Tls.Key)
-		if len(key) < 1 {
-			errDo(errors.New("the key is not empty, and can not find the file: " + c.conf.Tls.Key))
-			return err
-		}
-		ca := shared.GetFullPathFile(c.conf.Tls.CaPem)
-		if len(ca) < 1 {
-			errDo(errors.New("the ca pem is not empty, and can not find the file: " + c.conf.Tls.CaPem))
-			return err
-		}
-
-		var tc credentials.TransportCredentials
-		{
-			creds, err2 := tls.LoadX509KeyPair(pem, key)
-			if err2 != nil {
-				errDo(errors.WithStack(err2))
-				return err
-			}
+	tc, err2 := c.Tls.ServerConfig(c.conf.Tls)
+	if err2 != nil {
+		errDo(err2)
+		return err
+	}
+	if tc != nil {
+		logger.Infoln("serverNoblImp", zap.String("", "tls"), zap.Bool("ca", len(c.conf.Tls.CaPem) > 0))
+		c.server = grpc.NewServer(append(c.serverOptions(), grpc.Creds(credentials.NewTLS(tc)))...)
+	} else {
+		logger.Infoln("serverNoblImp", zap.String("", "no tls"))
+		c.server = grpc.NewServer(c.serverOptions()...)
+	}
 
-			caCert, err2 := ioutil.ReadFile(ca)
-			if err2 != nil {
-				errDo(errors.WithStack(err2))
-				return err
-			}
-			caCertPool := x509.NewCertPool()
-			if !caCertPool.AppendCertsFromPEM(caCert) {
-				errDo(errors.New("credentials: failed to append certificates"))
-				return err
-			}
+	if c.conf.Health.Enable {
+		c.health = newServerHealth(c.conf.Health, c.server)
+	}
+	if c.conf.Reflection {
+		reflection.Register(c.server)
+	}
+	return err
+}
 
-			tc = credentials.NewTLS(&tls.Config{
-				Certificates: []tls.Certificate{creds},
-				ClientCAs:    caCertPool, //server, use the ClientCAs
-				ClientAuth:   tls.RequireAndVerifyClientCert,
+//the options of the config, and the interceptors: panic recovery, then the configured interceptors
+func (c *serverNoblImp) serverOptions() []grpc.ServerOption {
+	recoveryUnary, recoveryStream := UnaryServerInterceptor(), StreamServerInterceptor()
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			atomic.AddInt64(&c.active, 1)
+			defer atomic.AddInt64(&c.active, -1)
+			return recoveryUnary(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return c.chain.unaryInterceptor(ctx, req, info, handler)
+			})
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			atomic.AddInt64(&c.active, 1)
+			defer atomic.AddInt64(&c.active, -1)
+			return recoveryStream(srv, stream, info, func(srv interface{}, stream grpc.ServerStream) error {
+				return c.chain.streamInterceptor(srv, stream, info, handler)
 			})
+		}),
+	}
+
+	conf := &c.conf
+	if conf.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(conf.MaxConcurrentStreams))
+	}
+	if conf.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(conf.MaxRecvMsgSize))
+	}
+	if conf.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(conf.MaxSendMsgSize))
+	}
+	if conf.ConnectionTimeout > 0 {
+		opts = append(opts, grpc.ConnectionTimeout(time.Duration(conf.ConnectionTimeout)*time.Second))
+	}
+	second := func(s int) time.Duration {
+		return time.Duration(s) * time.Second
+	}
+	if k := conf.Keepalive; k.MaxConnectionIdle > 0 || k.MaxConnectionAge > 0 || k.MaxConnectionAgeGrace > 0 || k.Time > 0 || k.Timeout > 0 {
+		opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionIdle:     second(k.MaxConnectionIdle),
+			MaxConnectionAge:      second(k.MaxConnectionAge),
+			MaxConnectionAgeGrace: second(k.MaxConnectionAgeGrace),
+			Time:                  second(k.Time),
+			Timeout:               second(k.Timeout),
+		}))
+	}
+	if k := conf.Keepalive; k.MinTime > 0 || k.PermitWithoutStream {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             second(k.MinTime),
+			PermitWithoutStream: k.PermitWithoutStream,
+		}))
+	}
+	return opts
+}
+
+//AfterAllInject resolve the configured interceptors, the dots have registered them
+//then register the grpc services of the dots, see GrpcRegister
+func (c *serverNoblImp) AfterAllInject(l dot.Line) {
+	if err := c.chain.resolve(c.conf.Interceptors); err != nil {
+		dot.Logger().Errorln("serverNoblImp", zap.Error(err))
+	}
+	if c.health != nil && l != nil {
+		c.health.inject(l)
+	}
+	if ll, ok := l.(dot.LivesLine); ok {
+		c.regErr = c.registerGrpc(ll.Lives())
+		if c.regErr != nil {
+			dot.Logger().Errorln("serverNoblImp", zap.Error(c.regErr))
 		}
-		logger.Infoln("serverNoblImp", zap.String("", "tls with ca"))
-
-		c.server = grpc.NewServer(grpc.Creds(tc), grpc.StreamInterceptor(StreamServerInterceptor()), grpc.UnaryInterceptor(UnaryServerInterceptor()))
-	case len(c.conf.Tls.Pem) > 0 && len(c.conf.Tls.Key) > 0:
-		pem := shared.GetFullPathFile(c.conf.Tls.Pem)
-		if len(pem) < 1 {
-			errDo(errors.New("the pem is not empty, and can not find the file: " + c.conf.Tls.Pem))
-			return err
+	} else if l != nil {
+		dot.Logger().Warnln("serverNoblImp", zap.String("", "the line can not list the lives, do not register the grpc services of the dots"))
+	}
+}
+
+//Start return the error of the GrpcRegister
+func (c *serverNoblImp) Start(ignore bool) error {
+	return c.regErr
+}
+
+//call the GrpcRegister of the lives, the lives in the config must register
+func (c *serverNoblImp) registerGrpc(lives []*dot.Live) error {
+	only := make(map[dot.LiveId]bool, len(c.conf.Register))
+	for _, id := range c.conf.Register {
+		only[id] = true
+	}
+	for _, live := range lives {
+		if live.Dot == nil || live.Dot == dot.Dot(c) || (len(only) > 0 && !only[live.LiveId]) {
+			continue
 		}
-		key := shared.GetFullPathFile(c.conf.Tls.Key)
-		if len(key) < 1 {
-			errDo(errors.New("the key is not empty, and can not find the file: " + c.conf.Tls.Key))
-			return err
+		r, ok := live.Dot.(GrpcRegister)
+		if !ok {
+			continue
 		}
-
-		//var tc credentials.TransportCredentials
-		//{
-		//	creds, err2 := tls.LoadX509KeyPair(pem, key)
-		//	if err2 != nil {
-		//		errDo(errors.WithStack(err2))
-		//		return err
-		//	}
-		//
-		//	tc = credentials.NewTLS(&tls.Config{
-		//		Certificates: []tls.Certificate{creds},
-		//	})
-		//}
-
-		tc, err2 := credentials.NewServerTLSFromFile(pem, key)
-		if err2 != nil {
-			errDo(errors.WithStack(err2))
-			return err
+		if err := registerGrpc(r, c.server); err != nil {
+			return errors.WithMessage(err, "register grpc of the live: "+live.LiveId.String())
+		}
+		delete(only, live.LiveId)
+	}
+	for _, id := range c.conf.Register {
+		if only[id] {
+			return dot.SError.NotExisted.AddNewError("the GrpcRegister of the live: " + id.String())
 		}
-		logger.Infoln("serverNoblImp", zap.String("", "tls no ca"))
-		c.server = grpc.NewServer(grpc.Creds(tc), grpc.StreamInterceptor(StreamServerInterceptor()), grpc.UnaryInterceptor(UnaryServerInterceptor()))
-
-	default:
-		logger.Infoln("serverNoblImp", zap.String("", "no tls"))
-		c.server = grpc.NewServer(grpc.StreamInterceptor(StreamServerInterceptor()), grpc.UnaryInterceptor(UnaryServerInterceptor()))
 	}
+	return nil
+}
 
-	return err
+func registerGrpc(r GrpcRegister, s *grpc.Server) (err error) {
+	defer func() {
+		if re := recover(); re != nil {
+			err = errors.Errorf("%v", re)
+		}
+	}()
+	return r.RegisterGrpc(s)
 }
 
 //Run after every component finished start, this can ensure all service has been registered on grpc server
 func (c *serverNoblImp) AfterAllStart(l dot.Line) {
+	if c.health != nil {
+		c.health.start()
+	}
 	c.startServer()
 }
 
 //Stop stop dot
 func (c *serverNoblImp) Stop(ignore bool) error {
+	atomic.StoreInt32(&c.draining, 1)
+	if c.health != nil { //NOT_SERVING first, so the load balancers do not send the new calls
+		c.health.shutdown()
+	}
 	if c.server != nil {
-		c.server.GracefulStop()
+		timeout := time.Duration(c.conf.StopTimeout) * time.Second
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		stopped := make(chan struct{})
+		go func(s *grpc.Server) {
+			s.GracefulStop()
+			close(stopped)
+		}(c.server)
+		timer := time.NewTimer(timeout)
+		select {
+		case <-stopped:
+			timer.Stop()
+		case <-timer.C:
+			dot.Logger().Warnln("serverNoblImp", zap.String("", "graceful stop timeout, cut off the calls"), zap.Int64("calls", atomic.LoadInt64(&c.active)))
+			c.server.Stop()
+			<-stopped
+		}
 		c.server = nil
 	}
 	return nil
 }
 
+func (c *serverNoblImp) Draining() bool {
+	return atomic.LoadInt32(&c.draining) == 1
+}
+
+func (c *serverNoblImp) MaxRecvMsgSize() int {
+	if c.conf.MaxRecvMsgSize > 0 {
+		return c.conf.MaxRecvMsgSize
+	}
+	return 4 * 1024 * 1024 //the default of grpc
+}
+
 func (c *serverNoblImp) Server() *grpc.Server {
 	return c.server
 }
 
+func (c *serverNoblImp) Health() *health.Server {
+	if c.health == nil {
+		return nil
+	}
+	return c.health.server
+}
+
 func (c *serverNoblImp) startServer() {
+	if c.regErr != nil {
+		dot.Logger().Errorln("serverNoblImp", zap.String("", "do not serve, the grpc services are not registered"), zap.Error(c.regErr))
+		return
+	}
+	c.logServices()
 	for _, lis := range c.listeners {
 		go func(li net.Listener) {
 			logger := dot.Logger()
@@ -225,3 +380,21 @@ func (c *serverNoblImp) startServer() {
 		}(lis)
 	}
 }
+
+//log the registered services and their methods
+func (c *serverNoblImp) logServices() {
+	info := c.server.GetServiceInfo()
+	names := make([]string, 0, len(info))
+	for name := range info {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	logger := dot.Logger()
+	for _, name := range names {
+		methods := make([]string, 0, len(info[name].Methods))
+		for _, it := range info[name].Methods {
+			methods = append(methods, it.Name)
+		}
+		logger.Infoln("serverNoblImp", zap.String("service", name), zap.Strings("methods", methods))
+	}
+}
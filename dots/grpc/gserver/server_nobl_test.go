@@ -0,0 +1,215 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package gserver
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/scryinfo/dot/dot"
+	"github.com/scryinfo/dot/dots/grpc/shared"
+	"github.com/scryinfo/dot/dots/line"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+//start the server with health service, return the client
+func startTestServer(t *testing.T, conf string) (*serverNoblImp, grpc_health_v1.HealthClient, func()) {
+	d, err := newServerNobl([]byte(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := d.(*serverNoblImp)
+	if err = c.Create(nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Health() == nil {
+		grpc_health_v1.RegisterHealthServer(c.Server(), health.NewServer())
+	}
+	c.AfterAllInject(nil)
+	c.AfterAllStart(nil)
+	cc, err := grpc.Dial(c.listeners[0].Addr().String(), grpc.WithInsecure(), grpc.WithContextDialer(shared.Dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, grpc_health_v1.NewHealthClient(cc), func() {
+		_ = cc.Close()
+		_ = c.Stop(false)
+	}
+}
+
+func TestServerNobl_Interceptors(t *testing.T) {
+	var order []string
+	mark := func(name string) ServerInterceptor {
+		return ServerInterceptor{Unary: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			order = append(order, name)
+			return handler(ctx, req)
+		}}
+	}
+	RegisterServerInterceptor("test1", mark("test1"))
+	RegisterServerInterceptor("test2", mark("test2"))
+	RegisterServerInterceptor("panic", ServerInterceptor{Unary: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		panic("test")
+	}})
+
+	_, client, stop := startTestServer(t, `{"addrs":["127.0.0.1:0"],"interceptors":["test2","test1"],"maxConcurrentStreams":10,"keepalive":{"time":60,"minTime":10}}`)
+	defer stop()
+	if _, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 2 || order[0] != "test2" || order[1] != "test1" {
+		t.Error(order)
+	}
+
+	_, client, stop2 := startTestServer(t, `{"addrs":["127.0.0.1:0"],"interceptors":["test1","panic"]}`)
+	defer stop2()
+	if _, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}); status.Code(err) != codes.Internal {
+		t.Error(err)
+	}
+
+	_, client, stop3 := startTestServer(t, `{"addrs":["127.0.0.1:0"],"interceptors":["unknown"]}`)
+	defer stop3()
+	if _, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}); status.Code(err) != codes.Internal {
+		t.Error(err)
+	}
+}
+
+type testStatuser struct {
+	status int32
+}
+
+func (c *testStatuser) Status() dot.StatusType {
+	return dot.StatusType(atomic.LoadInt32(&c.status))
+}
+
+func TestServerNobl_Health(t *testing.T) {
+	c, client, stop := startTestServer(t, `{"addrs":["inproc://health"],"health":{"enable":true},"reflection":true}`)
+	defer stop()
+	st := &testStatuser{}
+	c.health.dots["svc"] = st
+	c.health.check()
+
+	check := func(service string, want grpc_health_v1.HealthCheckResponse_ServingStatus) {
+		res, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+		if err != nil || res.Status != want {
+			t.Error(service, res, err)
+		}
+	}
+	check("", grpc_health_v1.HealthCheckResponse_SERVING)
+	check("svc", grpc_health_v1.HealthCheckResponse_SERVING)
+	atomic.StoreInt32(&st.status, 1)
+	c.health.check()
+	check("svc", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	if _, ok := c.Server().GetServiceInfo()["grpc.reflection.v1alpha.ServerReflection"]; !ok {
+		t.Error("no reflection service")
+	}
+
+	atomic.StoreInt32(&st.status, 0)
+	c.health.shutdown() //the first step of Stop
+	c.health.check()
+	check("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	check("svc", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+}
+
+func TestServerNobl_StopTimeout(t *testing.T) {
+	c, client, stop := startTestServer(t, `{"addrs":["127.0.0.1:0"],"stopTimeout":1}`)
+	defer stop()
+	stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt64(&c.active); n != 1 {
+		t.Error(n)
+	}
+
+	start := time.Now()
+	_ = c.Stop(false) //the watch stream do not end, GracefulStop is blocked
+	if d := time.Since(start); d < time.Second || d > 3*time.Second {
+		t.Error(d)
+	}
+	if !c.Draining() {
+		t.Error("not draining")
+	}
+	if _, err = stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Error(err)
+	}
+}
+
+type testGrpcRegister struct {
+	err error
+}
+
+func (c *testGrpcRegister) RegisterGrpc(s *grpc.Server) error {
+	if c.err == nil {
+		grpc_health_v1.RegisterHealthServer(s, health.NewServer())
+	}
+	return c.err
+}
+
+type testPanicRegister struct{}
+
+func (c testPanicRegister) RegisterGrpc(s *grpc.Server) error {
+	panic("test")
+}
+
+func TestServerNobl_GrpcRegister(t *testing.T) {
+	reg := &testGrpcRegister{}
+	l, err := line.BuildAndStart(func(l dot.Line) error {
+		return l.PreAdd(&dot.TypeLives{
+			Meta: dot.Metadata{TypeId: ServerNoblTypeId, NewDoter: func(conf interface{}) (dot.Dot, error) {
+				return newServerNobl([]byte(`{"addrs":["inproc://register"]}`))
+			}},
+		}, &dot.TypeLives{
+			Meta: dot.Metadata{TypeId: "testGrpcRegister", NewDoter: func(conf interface{}) (dot.Dot, error) {
+				return reg, nil
+			}},
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.ToLifer().Stop(true) //do not destroy, the logger of the line is used by the other tests
+
+	cc, err := grpc.Dial("inproc://register", grpc.WithInsecure(), grpc.WithContextDialer(shared.Dial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	if _, err = grpc_health_v1.NewHealthClient(cc).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}); err != nil {
+		t.Error(err)
+	}
+
+	//the failures, the server does not start
+	for i, it := range []struct {
+		conf  string
+		lives []*dot.Live
+	}{
+		{`{}`, []*dot.Live{{LiveId: "one", Dot: &testGrpcRegister{err: errors.New("test")}}}},
+		{`{}`, []*dot.Live{{LiveId: "one", Dot: testPanicRegister{}}}},
+		{`{"register":["none"]}`, []*dot.Live{{LiveId: "one", Dot: reg}}},
+	} {
+		d, err := newServerNobl([]byte(it.conf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := d.(*serverNoblImp)
+		if err = c.Create(nil); err != nil {
+			t.Fatal(err)
+		}
+		c.regErr = c.registerGrpc(it.lives)
+		if err = c.Start(false); err == nil {
+			t.Error(i, "no error")
+		}
+		_ = c.Stop(false)
+	}
+}
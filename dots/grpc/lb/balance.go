@@ -4,34 +4,80 @@
 package lb
 
 import (
+	"fmt"
 	"strings"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
 )
 
 const (
-	Round = "round"
-	First = "first"
+	Round    = "round"
+	First    = "first"
+	Weighted = "weighted" //weighted round robin, the weights are in BalanceOptions
+	Least    = "least"    //least outstanding requests
+	P2c      = "p2c"      //power of two choices, the less outstanding requests one of two random addresses
+	Hash     = "hash"     //consistent hashing on the value of the metadata key(BalanceOptions.HashKey)
+
+	//DefaultHashKey the default metadata key of hash balance
+	DefaultHashKey = "lb-hash"
 )
 
+//BalanceOptions the options of the balancers for one service
+type BalanceOptions struct {
+	Weights  map[string]int //key: address, value: weight, the default weight is 1
+	HashKey  string         //the metadata key of hash balance, the default value is DefaultHashKey
+	Breakers *Breakers      //the circuit breakers of the addresses, nil means disable, First balance do not support it
+	States   *AddrStates    //record the connectivity states of the addresses, nil means disable, First balance do not support it
+}
+
+//the value of the address attributes
+type balanceAttrKey struct{}
+type balanceAttr struct {
+	weight   int
+	hashKey  string
+	breakers *Breakers
+	states   *AddrStates
+}
+
+func balanceAttrOf(addr resolver.Address) balanceAttr {
+	attr := balanceAttr{weight: 1}
+	if addr.Attributes != nil {
+		if a, ok := addr.Attributes.Value(balanceAttrKey{}).(balanceAttr); ok {
+			attr = a
+		}
+	}
+	if len(attr.hashKey) < 1 {
+		attr.hashKey = DefaultHashKey
+	}
+	return attr
+}
+
 //If not found, then return Round Balance
 func Balance(bname string) grpc.DialOption {
 	var do grpc.DialOption = nil
-	switch strings.ToLower(bname) {
+	switch name := strings.ToLower(bname); name {
 	case Round:
 		do = BalancerRound()
 	case First:
 		do = BalancerFirst()
+	case Weighted, Least, P2c, Hash:
+		do = balancerConfig(name)
 	default:
 		do = BalancerRound()
 	}
 	return do
 }
 
-func BalancerRound() grpc.DialOption { //todo grpc realization balance management use global variables, and do not consider multi thread condition, this is a temporary point
-	return grpc.WithBalancerName(Round)
+func BalancerRound() grpc.DialOption {
+	return balancerConfig(Round)
+}
+
+func BalancerFirst() grpc.DialOption {
+	return balancerConfig(grpc.PickFirstBalancerName)
 }
 
-func BalancerFirst() grpc.DialOption { //todo grpc realization balance management use global variables, and do not consider multi thread condition, this is a temporary point
-	return grpc.WithBalancerName(grpc.PickFirstBalancerName)
+//the balancer is the config of every ClientConn, the registered builders are only the factories
+func balancerConfig(name string) grpc.DialOption {
+	return grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingPolicy":%q}`, name))
 }
@@ -0,0 +1,225 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package lb
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+//the status of service "s" in the servers: SERVING, NOT_SERVING, SERVICE_UNKNOWN
+var testStatuses = []grpc_health_v1.HealthCheckResponse_ServingStatus{
+	grpc_health_v1.HealthCheckResponse_SERVING,
+	grpc_health_v1.HealthCheckResponse_NOT_SERVING,
+	grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN,
+}
+
+func startTestServers(t *testing.T) ([]string, func()) {
+	var servers []*grpc.Server
+	var addrs []string
+	for i := range testStatuses {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := grpc.NewServer()
+		h := health.NewServer()
+		if testStatuses[i] != grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN {
+			h.SetServingStatus("s", testStatuses[i])
+		}
+		grpc_health_v1.RegisterHealthServer(s, h)
+		go func() { _ = s.Serve(lis) }()
+		servers = append(servers, s)
+		addrs = append(addrs, lis.Addr().String())
+	}
+	return addrs, func() {
+		for _, s := range servers {
+			s.Stop()
+		}
+	}
+}
+
+func dialTest(t *testing.T, balance string, addrs []string, opts BalanceOptions) *grpc.ClientConn {
+	b := NewClientBuilder("test", map[string][]string{"svc": addrs})
+	b.SetBalanceOptions("svc", opts)
+	cc, err := grpc.Dial("test:///svc", grpc.WithResolvers(b), Balance(balance), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for s := cc.GetState(); s != connectivity.Ready; s = cc.GetState() {
+		if !cc.WaitForStateChange(ctx, s) {
+			t.Fatal("timeout")
+		}
+	}
+	time.Sleep(100 * time.Millisecond) //all addresses are ready
+	return cc
+}
+
+//return the count of every address
+func callTest(t *testing.T, cc *grpc.ClientConn, n int, ctx func(i int) context.Context) map[string]int {
+	counts := make(map[string]int)
+	client := grpc_health_v1.NewHealthClient(cc)
+	for i := 0; i < n; i++ {
+		var p peer.Peer
+		if _, err := client.Check(ctx(i), &grpc_health_v1.HealthCheckRequest{}, grpc.Peer(&p)); err != nil {
+			t.Fatal(err)
+		}
+		counts[p.Addr.String()]++
+	}
+	return counts
+}
+
+func TestBalance_Weighted(t *testing.T) {
+	addrs, stop := startTestServers(t)
+	defer stop()
+	cc := dialTest(t, Weighted, addrs, BalanceOptions{Weights: map[string]int{addrs[0]: 3, addrs[1]: 2}})
+	defer cc.Close()
+
+	counts := callTest(t, cc, 60, func(int) context.Context { return context.Background() })
+	if counts[addrs[0]] != 30 || counts[addrs[1]] != 20 || counts[addrs[2]] != 10 {
+		t.Error(counts)
+	}
+}
+
+func TestBalance_Hash(t *testing.T) {
+	addrs, stop := startTestServers(t)
+	defer stop()
+	cc := dialTest(t, Hash, addrs, BalanceOptions{HashKey: "user"})
+	defer cc.Close()
+
+	users := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	first := make(map[string]string)
+	for round := 0; round < 3; round++ {
+		for _, u := range users {
+			counts := callTest(t, cc, 1, func(int) context.Context {
+				return metadata.AppendToOutgoingContext(context.Background(), "user", u)
+			})
+			for addr := range counts {
+				if round == 0 {
+					first[u] = addr
+				} else if first[u] != addr {
+					t.Error("not sticky", u, first[u], addr)
+				}
+			}
+		}
+	}
+	distinct := make(map[string]bool)
+	for _, addr := range first {
+		distinct[addr] = true
+	}
+	if len(distinct) < 2 {
+		t.Error("all users are in one address", first)
+	}
+
+	//no hash key, in turn
+	counts := callTest(t, cc, 30, func(int) context.Context { return context.Background() })
+	for _, addr := range addrs {
+		if counts[addr] != 10 {
+			t.Error(counts)
+		}
+	}
+}
+
+func TestBalance_HashBreaker(t *testing.T) {
+	addrs, stop := startTestServers(t)
+	defer stop()
+	breakers := NewBreakers(BreakerConfig{Failures: 1})
+	cc := dialTest(t, Hash, addrs, BalanceOptions{HashKey: "user", Breakers: breakers})
+	defer cc.Close()
+
+	user := func(int) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "user", "a")
+	}
+	var first string
+	for addr := range callTest(t, cc, 1, user) {
+		first = addr
+	}
+	breakers.done(first, status.Error(codes.Unavailable, "")) //open
+	counts := callTest(t, cc, 3, user)                        //the next address of the ring, and sticky
+	if len(counts) != 1 || counts[first] > 0 {
+		t.Error(first, counts)
+	}
+}
+
+//one address is open, the others are picked by every policy, and no call fails
+func TestBalance_Breaker(t *testing.T) {
+	addrs, stop := startTestServers(t)
+	defer stop()
+	for _, balance := range []string{Round, Weighted, Least, P2c, Hash} {
+		breakers := NewBreakers(BreakerConfig{Failures: 1})
+		cc := dialTest(t, balance, addrs, BalanceOptions{Weights: map[string]int{addrs[0]: 10}, Breakers: breakers})
+		breakers.done(addrs[0], status.Error(codes.Unavailable, "")) //open the heavy one of weighted
+		counts := callTest(t, cc, 30, func(int) context.Context { return context.Background() })
+		if counts[addrs[0]] > 0 || len(counts) != len(addrs)-1 {
+			t.Error(balance, counts)
+		}
+		_ = cc.Close()
+	}
+}
+
+//open the watch streams and keep them, return the count of every server
+func watchTest(t *testing.T, cc *grpc.ClientConn, n int) ([]int, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := grpc_health_v1.NewHealthClient(cc)
+	counts := make([]int, len(testStatuses))
+	for i := 0; i < n; i++ {
+		stream, err := client.Watch(ctx, &grpc_health_v1.HealthCheckRequest{Service: "s"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, it := range testStatuses {
+			if it == res.Status {
+				counts[j]++
+			}
+		}
+	}
+	return counts, cancel
+}
+
+func TestBalance_Least(t *testing.T) {
+	addrs, stop := startTestServers(t)
+	defer stop()
+	cc := dialTest(t, Least, addrs, BalanceOptions{})
+	defer cc.Close()
+
+	counts, cancel := watchTest(t, cc, 9)
+	defer cancel()
+	for _, it := range counts {
+		if it != 3 {
+			t.Error(counts)
+		}
+	}
+}
+
+func TestBalance_P2c(t *testing.T) {
+	addrs, stop := startTestServers(t)
+	defer stop()
+	cc := dialTest(t, P2c, addrs, BalanceOptions{})
+	defer cc.Close()
+
+	counts, cancel := watchTest(t, cc, 30)
+	defer cancel()
+	for _, it := range counts {
+		if it < 1 || it > 15 { //the most one of two is never picked
+			t.Error(counts)
+		}
+	}
+}
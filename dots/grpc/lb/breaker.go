@@ -0,0 +1,192 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package lb
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "halfOpen" //try one request
+)
+
+//BreakerConfig the circuit breaker of every address
+type BreakerConfig struct {
+	Failures int `json:"failures"` //open after the consecutive failures, 0 means disable
+	OpenTime int `json:"openTime"` //second, after the time, try one request, the default value is 30
+}
+
+//Breakers the circuit breakers of the addresses of one service
+//the failures are the codes of the server side: Unavailable, Internal, Unknown
+//DeadlineExceeded is not a failure, the deadline of a caller may be too short, it should not open the circuit for everyone
+type Breakers struct {
+	failures int
+	openTime time.Duration
+	mutex    sync.Mutex
+	states   map[string]*breakerState //key: address
+}
+
+type breakerState struct {
+	failures int
+	openAt   time.Time //zero means closed
+	trying   bool      //half open, and a request is trying
+}
+
+//NewBreakers return nil if the breaker is disabled
+func NewBreakers(conf BreakerConfig) *Breakers {
+	if conf.Failures < 1 {
+		return nil
+	}
+	c := &Breakers{
+		failures: conf.Failures,
+		openTime: time.Duration(conf.OpenTime) * time.Second,
+		states:   make(map[string]*breakerState),
+	}
+	if c.openTime <= 0 {
+		c.openTime = 30 * time.Second
+	}
+	return c
+}
+
+//State return the state of the address, BreakerClosed, BreakerOpen or BreakerHalfOpen
+func (c *Breakers) State(addr string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.state(c.states[addr])
+}
+
+//States return the states of all the addresses that have been called
+func (c *Breakers) States() map[string]string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	res := make(map[string]string, len(c.states))
+	for addr, it := range c.states {
+		res[addr] = c.state(it)
+	}
+	return res
+}
+
+func (c *Breakers) state(s *breakerState) string {
+	switch {
+	case s == nil || s.openAt.IsZero():
+		return BreakerClosed
+	case time.Since(s.openAt) < c.openTime:
+		return BreakerOpen
+	default:
+		return BreakerHalfOpen
+	}
+}
+
+func (c *Breakers) allow(addr string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	s := c.states[addr]
+	switch c.state(s) {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		if s.trying {
+			return false
+		}
+		s.trying = true
+	}
+	return true
+}
+
+func (c *Breakers) done(addr string, err error) {
+	failed := false
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Internal, codes.Unknown:
+		failed = true
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	s := c.states[addr]
+	if s == nil {
+		s = &breakerState{}
+		c.states[addr] = s
+	}
+	s.trying = false
+	if !failed {
+		s.failures = 0
+		s.openAt = time.Time{}
+		return
+	}
+	s.failures++
+	if s.failures >= c.failures || !s.openAt.IsZero() { //the trying request of half open is failed, open again
+		s.openAt = time.Now()
+	}
+}
+
+//wrap the picker with the breakers of the addresses, if there are no breakers, return the picker
+func withBreakers(info base.PickerBuildInfo, p balancer.V2Picker) balancer.V2Picker {
+	var breakers *Breakers
+	addrs := make(map[balancer.SubConn]string, len(info.ReadySCs))
+	for sc, it := range info.ReadySCs {
+		breakers = balanceAttrOf(it.Address).breakers
+		addrs[sc] = it.Address.Addr
+	}
+	if breakers == nil {
+		return p
+	}
+	return &breakerPicker{picker: p, breakers: breakers, addrs: addrs}
+}
+
+//the picker skips the excepted sub connections, all pickers of the package implement it
+type exceptPicker interface {
+	pickExcept(info balancer.PickInfo, except map[balancer.SubConn]bool) (balancer.PickResult, error)
+}
+
+//pick from the picker again if the breaker of the address is open, the open ones are excepted
+type breakerPicker struct {
+	picker   balancer.V2Picker
+	breakers *Breakers
+	addrs    map[balancer.SubConn]string
+}
+
+func (c *breakerPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	var except map[balancer.SubConn]bool //the open ones
+	ep, _ := c.picker.(exceptPicker)
+	for i := 0; i < len(c.addrs); i++ {
+		var res balancer.PickResult
+		var err error
+		if ep != nil && len(except) > 0 {
+			res, err = ep.pickExcept(info, except)
+		} else {
+			res, err = c.picker.Pick(info)
+		}
+		if err != nil {
+			return res, err
+		}
+		addr := c.addrs[res.SubConn]
+		if !c.breakers.allow(addr) {
+			if res.Done != nil {
+				res.Done(balancer.DoneInfo{})
+			}
+			if except == nil {
+				except = make(map[balancer.SubConn]bool, len(c.addrs))
+			}
+			except[res.SubConn] = true
+			continue
+		}
+		done := res.Done
+		res.Done = func(di balancer.DoneInfo) {
+			c.breakers.done(addr, di.Err)
+			if done != nil {
+				done(di)
+			}
+		}
+		return res, nil
+	}
+	return balancer.PickResult{}, status.Error(codes.Unavailable, "the circuit breakers of all addresses are open")
+}
@@ -0,0 +1,63 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package lb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBreakers(t *testing.T) {
+	if NewBreakers(BreakerConfig{}) != nil {
+		t.Error("the breaker is not disabled")
+	}
+	b := NewBreakers(BreakerConfig{Failures: 2})
+	b.openTime = 50 * time.Millisecond
+	unavailable := status.Error(codes.Unavailable, "")
+
+	b.done("a", unavailable)
+	b.done("a", status.Error(codes.NotFound, "")) //not a failure, reset
+	b.done("a", unavailable)
+	if b.State("a") != BreakerClosed || !b.allow("a") {
+		t.Fatal(b.States())
+	}
+	b.done("a", unavailable)
+	if b.State("a") != BreakerOpen || b.allow("a") {
+		t.Fatal(b.States())
+	}
+	if b.State("b") != BreakerClosed || !b.allow("b") {
+		t.Error(b.States())
+	}
+	deadline := status.Error(codes.DeadlineExceeded, "") //the deadline of the caller, not a failure
+	b.done("c", deadline)
+	b.done("c", deadline)
+	if b.State("c") != BreakerClosed {
+		t.Error(b.States())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if b.State("a") != BreakerHalfOpen || !b.allow("a") {
+		t.Fatal(b.States())
+	}
+	if b.allow("a") {
+		t.Error("only one request is allowed in half open")
+	}
+	b.done("a", errors.New("unknown")) //open again
+	if b.State("a") != BreakerOpen {
+		t.Fatal(b.States())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if !b.allow("a") {
+		t.Fatal(b.States())
+	}
+	b.done("a", nil)
+	if b.State("a") != BreakerClosed || !b.allow("a") {
+		t.Error(b.States())
+	}
+}
@@ -4,50 +4,138 @@
 package lb
 
 import (
+	"sync"
+
+	"google.golang.org/grpc/attributes"
 	"google.golang.org/grpc/resolver"
 )
 
-//Client load balancing
-type clientBuilder struct {
+//ClientBuilder Client load balancing, the addresses of the services can be updated at runtime
+type ClientBuilder struct {
 	scheme       string
 	serviceAddrs map[string][]string //key service name, value service corresponding address(such as 12.23.23.23：909）
+	options      map[string]BalanceOptions
+	attrs        map[balanceAttr]*attributes.Attributes //the same attributes for the same value, grpc compare the addresses with the pointer
+	resolvers    map[*clientResolver]bool
+	mutex        sync.Mutex
 }
 
-func NewClientBuilder(schema string, serviceAddrs map[string][]string) resolver.Builder {
-	return &clientBuilder{
+func NewClientBuilder(schema string, serviceAddrs map[string][]string) *ClientBuilder {
+	c := &ClientBuilder{
 		scheme:       schema,
-		serviceAddrs: serviceAddrs,
+		serviceAddrs: make(map[string][]string, len(serviceAddrs)),
+		options:      make(map[string]BalanceOptions),
+		attrs:        make(map[balanceAttr]*attributes.Attributes),
+		resolvers:    make(map[*clientResolver]bool),
+	}
+	for k, v := range serviceAddrs {
+		c.serviceAddrs[k] = append([]string(nil), v...)
 	}
+	return c
 }
 
-func (c *clientBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOption) (resolver.Resolver, error) {
+func (c *ClientBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
 	r := &clientResolver{
-		target:     target,
-		conn:       cc,
-		addrsStore: c.serviceAddrs,
+		target:  target,
+		conn:    cc,
+		builder: c,
 	}
+	c.mutex.Lock()
+	c.resolvers[r] = true
+	c.mutex.Unlock()
 	r.start()
 	return r, nil
 }
-func (c *clientBuilder) Scheme() string { return c.scheme }
+func (c *ClientBuilder) Scheme() string { return c.scheme }
 
-type clientResolver struct {
-	target     resolver.Target
-	conn       resolver.ClientConn
-	addrsStore map[string][]string
+//Addrs return the current addresses of the service
+func (c *ClientBuilder) Addrs(serviceName string) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]string(nil), c.serviceAddrs[serviceName]...)
 }
 
-func (c clientResolver) ResolveNow(resolver.ResolveNowOption) {
+//UpdateAddrs replace the addresses of the service, and push them to every live ClientConn of the service
+func (c *ClientBuilder) UpdateAddrs(serviceName string, addrs []string) {
+	addrs = append([]string(nil), addrs...)
+	var rs []*clientResolver
+	c.mutex.Lock()
+	c.serviceAddrs[serviceName] = addrs
+	for r := range c.resolvers {
+		if r.target.Endpoint == serviceName {
+			rs = append(rs, r)
+		}
+	}
+	c.mutex.Unlock()
+
+	for _, r := range rs {
+		r.update()
+	}
 }
 
-func (c clientResolver) Close() {
+//SetBalanceOptions set the options of the service, call it before dial
+func (c *ClientBuilder) SetBalanceOptions(serviceName string, opts BalanceOptions) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.options[serviceName] = opts
 }
 
-func (c *clientResolver) start() {
-	addrStrs := c.addrsStore[c.target.Endpoint]
+//make the resolver addresses of the service, the balance options are in the attributes
+func (c *ClientBuilder) addresses(serviceName string, addrStrs []string) []resolver.Address {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	opts := c.options[serviceName]
 	addrs := make([]resolver.Address, len(addrStrs))
 	for i, s := range addrStrs {
-		addrs[i] = resolver.Address{Addr: s}
+		attr := balanceAttr{weight: opts.Weights[s], hashKey: opts.HashKey, breakers: opts.Breakers, states: opts.States}
+		if attr.weight < 1 {
+			attr.weight = 1
+		}
+		a, ok := c.attrs[attr]
+		if !ok {
+			a = attributes.New(balanceAttrKey{}, attr)
+			c.attrs[attr] = a
+		}
+		addrs[i] = resolver.Address{Addr: s, Attributes: a}
+	}
+	return addrs
+}
+
+func (c *ClientBuilder) remove(r *clientResolver) {
+	c.mutex.Lock()
+	delete(c.resolvers, r)
+	c.mutex.Unlock()
+}
+
+type clientResolver struct {
+	target  resolver.Target
+	conn    resolver.ClientConn
+	builder *ClientBuilder
+	mutex   sync.Mutex //the updates are in order
+	closed  bool
+}
+
+func (c *clientResolver) ResolveNow(resolver.ResolveNowOptions) {
+	go c.update() //do not block the grpc
+}
+
+func (c *clientResolver) Close() {
+	c.mutex.Lock()
+	c.closed = true
+	c.mutex.Unlock()
+	c.builder.remove(c)
+}
+
+func (c *clientResolver) start() {
+	c.update()
+}
+
+//read the current addresses under the mutex, so an older address list never overwrites a newer one
+func (c *clientResolver) update() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.closed {
+		addrs := c.builder.addresses(c.target.Endpoint, c.builder.Addrs(c.target.Endpoint))
+		c.conn.UpdateState(resolver.State{Addresses: addrs})
 	}
-	c.conn.UpdateState(resolver.State{Addresses: addrs})
 }
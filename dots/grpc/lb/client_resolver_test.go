@@ -0,0 +1,90 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package lb
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+)
+
+type testClientConn struct {
+	resolver.ClientConn
+	mutex sync.Mutex
+	addrs []string
+}
+
+func (c *testClientConn) UpdateState(s resolver.State) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.addrs = c.addrs[:0]
+	for _, it := range s.Addresses {
+		c.addrs = append(c.addrs, it.Addr)
+	}
+}
+
+func (c *testClientConn) Addrs() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]string(nil), c.addrs...)
+}
+
+func TestClientBuilder_UpdateAddrs(t *testing.T) {
+	b := NewClientBuilder("test", map[string][]string{"s1": {"a:1"}, "s2": {"b:1"}})
+	cc1 := &testClientConn{}
+	cc2 := &testClientConn{}
+	r1, _ := b.Build(resolver.Target{Scheme: "test", Endpoint: "s1"}, cc1, resolver.BuildOptions{})
+	r2, _ := b.Build(resolver.Target{Scheme: "test", Endpoint: "s2"}, cc2, resolver.BuildOptions{})
+	defer r2.Close()
+	if a := cc1.Addrs(); len(a) != 1 || a[0] != "a:1" {
+		t.Fatal(a)
+	}
+
+	b.UpdateAddrs("s1", []string{"a:2", "a:3"})
+	if a := cc1.Addrs(); len(a) != 2 || a[0] != "a:2" || a[1] != "a:3" {
+		t.Error(a)
+	}
+	if a := cc2.Addrs(); len(a) != 1 || a[0] != "b:1" {
+		t.Error(a)
+	}
+
+	r1.Close()
+	b.UpdateAddrs("s1", []string{"a:4"})
+	if a := cc1.Addrs(); len(a) != 2 {
+		t.Error("closed resolver is updated", a)
+	}
+	if a := b.Addrs("s1"); len(a) != 1 || a[0] != "a:4" {
+		t.Error(a)
+	}
+}
+
+//the concurrent updates, the last addresses are in the ClientConn
+func TestClientBuilder_UpdateRace(t *testing.T) {
+	b := NewClientBuilder("test", map[string][]string{"s": {"a:0"}})
+	cc := &testClientConn{}
+	r, _ := b.Build(resolver.Target{Scheme: "test", Endpoint: "s"}, cc, resolver.BuildOptions{})
+	defer r.Close()
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 50; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			b.UpdateAddrs("s", []string{"a:" + strconv.Itoa(i)})
+		}(i)
+		go func() {
+			defer wg.Done()
+			r.ResolveNow(resolver.ResolveNowOptions{})
+		}()
+	}
+	wg.Wait()
+	time.Sleep(100 * time.Millisecond) //the updates of ResolveNow
+	want := b.Addrs("s")
+	if a := cc.Addrs(); len(a) != 1 || a[0] != want[0] {
+		t.Error(a, want)
+	}
+}
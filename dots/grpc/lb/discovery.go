@@ -0,0 +1,299 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package lb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scryinfo/dot/dot"
+	"github.com/scryinfo/dot/dots/grpc/shared"
+	"go.uber.org/zap"
+)
+
+const (
+	DiscoveryStatic = "static"
+	DiscoveryDns    = "dns"
+	DiscoverySrv    = "srv"
+	DiscoveryFile   = "file"
+	DiscoveryHttp   = "http"
+)
+
+//DiscoveryConfig how to find the addresses of one service
+type DiscoveryConfig struct {
+	//static(the default value), dns(A/AAAA records), srv(SRV records), file, http
+	Type string `json:"type"`
+	//dns: host name, sample: "hi.example.com"
+	//srv: service name, sample: "_grpc._tcp.example.com"
+	//file: the file of addresses, one address per line or json array, if it is not abs path, preferred to use the executable path
+	//http: url that return the json, sample: ["1.1.1.1:568"] or {"addrs":["1.1.1.1:568"]}
+	Target string `json:"target"`
+	//the port of dns A/AAAA records
+	Port int `json:"port"`
+	//second, re-resolve interval, the default value is 30, file is 5
+	Interval int `json:"interval"`
+}
+
+//Discovery find the addresses of one service
+type Discovery interface {
+	//Start begin to find the addresses, call the update when the addresses change, it does not block
+	Start(update func(addrs []string))
+	//Stop stop the discovery
+	Stop()
+}
+
+//NewDiscoveryFunc make the Discovery of one service
+type NewDiscoveryFunc = func(serviceName string, conf DiscoveryConfig) (Discovery, error)
+
+var (
+	discoveries      = make(map[string]NewDiscoveryFunc)
+	discoveriesMutex sync.Mutex
+)
+
+//RegisterDiscovery register the other type of discovery, it should be called before the conns is created
+func RegisterDiscovery(typ string, newer NewDiscoveryFunc) {
+	discoveriesMutex.Lock()
+	defer discoveriesMutex.Unlock()
+	discoveries[strings.ToLower(typ)] = newer
+}
+
+//NewDiscovery make the Discovery of the config, staticAddrs are the addresses of static discovery
+func NewDiscovery(serviceName string, conf DiscoveryConfig, staticAddrs []string) (Discovery, error) {
+	discoveriesMutex.Lock()
+	newer := discoveries[strings.ToLower(conf.Type)]
+	discoveriesMutex.Unlock()
+	if newer != nil {
+		return newer(serviceName, conf)
+	}
+	d, err := newPollDiscovery(serviceName, conf, staticAddrs)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func newPollDiscovery(serviceName string, conf DiscoveryConfig, staticAddrs []string) (*pollDiscovery, error) {
+	d := &pollDiscovery{
+		name:     serviceName,
+		interval: time.Duration(conf.Interval) * time.Second,
+	}
+	if d.interval <= 0 {
+		d.interval = 30 * time.Second
+	}
+	typ := strings.ToLower(conf.Type)
+	if typ != DiscoveryStatic && len(typ) > 0 && len(conf.Target) < 1 {
+		return nil, errors.New("the target of discovery is empty, service: " + serviceName)
+	}
+
+	switch typ {
+	case DiscoveryStatic, "":
+		addrs := append([]string(nil), staticAddrs...)
+		d.resolve = func() ([]string, error) {
+			return addrs, nil
+		}
+		d.interval = 0 //resolve once
+	case DiscoveryDns:
+		if conf.Port < 1 {
+			return nil, errors.New("the port of dns discovery is empty, service: " + serviceName)
+		}
+		d.resolve = func() ([]string, error) {
+			return resolveDns(conf.Target, conf.Port)
+		}
+	case DiscoverySrv:
+		d.resolve = func() ([]string, error) {
+			return resolveSrv(conf.Target)
+		}
+	case DiscoveryFile:
+		if conf.Interval < 1 {
+			d.interval = 5 * time.Second
+		}
+		file := shared.GetFullPathFile(conf.Target)
+		if len(file) < 1 { //the file may be created later
+			file = conf.Target
+		}
+		var modTime time.Time
+		var last []string
+		d.resolve = func() ([]string, error) {
+			st, err := os.Stat(file)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if st.ModTime().Equal(modTime) && last != nil { //do not change
+				return last, nil
+			}
+			addrs, err := resolveFile(file)
+			if err == nil {
+				modTime = st.ModTime()
+				last = addrs
+			}
+			return addrs, err
+		}
+	case DiscoveryHttp:
+		client := &http.Client{Timeout: 10 * time.Second}
+		d.resolve = func() ([]string, error) {
+			return resolveHttp(client, conf.Target)
+		}
+	default:
+		return nil, errors.New("the type of discovery is not supported: " + conf.Type)
+	}
+	return d, nil
+}
+
+//resolve the addresses at once, then re-resolve them per interval,
+//if it is error or no address, log it and keep the last addresses
+type pollDiscovery struct {
+	name     string
+	interval time.Duration //0 means resolve once
+	resolve  func() ([]string, error)
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (c *pollDiscovery) Start(update func(addrs []string)) {
+	c.stop = make(chan struct{})
+	var last []string
+	do := func() {
+		addrs, err := c.resolve()
+		switch {
+		case err != nil:
+			dot.Logger().Errorln("lb.Discovery", zap.String("service", c.name), zap.Error(err))
+		case len(addrs) < 1:
+			dot.Logger().Warnln("lb.Discovery", zap.String("service", c.name), zap.String("", "no address, keep the last addresses"))
+		case !reflect.DeepEqual(addrs, last):
+			last = addrs
+			update(addrs)
+		}
+	}
+	do()
+	if c.interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				do()
+			}
+		}
+	}()
+}
+
+func (c *pollDiscovery) Stop() {
+	c.stopOnce.Do(func() {
+		if c.stop != nil {
+			close(c.stop)
+		}
+	})
+}
+
+func resolveDns(host string, port int) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.JoinHostPort(ip, strconv.Itoa(port)))
+	}
+	return sortAddrs(addrs), nil
+}
+
+func resolveSrv(name string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, srvs, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	addrs := make([]string, 0, len(srvs))
+	for _, it := range srvs {
+		addrs = append(addrs, net.JoinHostPort(strings.TrimSuffix(it.Target, "."), strconv.Itoa(int(it.Port))))
+	}
+	return sortAddrs(addrs), nil
+}
+
+func resolveFile(file string) ([]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return parseAddrs(data)
+}
+
+func resolveHttp(client *http.Client, url string) ([]string, error) {
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("http discovery: " + res.Status)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return parseAddrs(data)
+}
+
+//json array, json object with "addrs", or one address per line(the line begin with "#" is comment)
+func parseAddrs(data []byte) ([]string, error) {
+	data = bytes.TrimSpace(data)
+	var addrs []string
+	switch {
+	case len(data) < 1:
+	case data[0] == '[':
+		if err := json.Unmarshal(data, &addrs); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	case data[0] == '{':
+		obj := struct {
+			Addrs []string `json:"addrs"`
+		}{}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		addrs = obj.Addrs
+	default:
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if len(line) > 0 && !strings.HasPrefix(line, "#") {
+				addrs = append(addrs, line)
+			}
+		}
+	}
+	return sortAddrs(addrs), nil
+}
+
+//the order of the addresses do not matter, sort them for comparing
+func sortAddrs(addrs []string) []string {
+	res := make([]string, 0, len(addrs))
+	for _, it := range addrs {
+		if it = strings.TrimSpace(it); len(it) > 0 {
+			res = append(res, it)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
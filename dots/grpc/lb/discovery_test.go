@@ -0,0 +1,164 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package lb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testUpdate struct {
+	mutex sync.Mutex
+	addrs []string
+	count int
+}
+
+func (c *testUpdate) update(addrs []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.addrs = addrs
+	c.count++
+}
+
+//wait for the addresses
+func (c *testUpdate) wait(addrs ...string) []string {
+	var last []string
+	for i := 0; i < 200; i++ {
+		c.mutex.Lock()
+		last = c.addrs
+		c.mutex.Unlock()
+		if len(last) == len(addrs) {
+			same := true
+			for j := range addrs {
+				same = same && last[j] == addrs[j]
+			}
+			if same {
+				return nil
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return last
+}
+
+func TestParseAddrs(t *testing.T) {
+	for _, it := range []string{`["b:1", "a:1"]`, `{"addrs":["a:1","b:1"]}`, "# comment\n b:1\n\na:1\n"} {
+		addrs, err := parseAddrs([]byte(it))
+		if err != nil || len(addrs) != 2 || addrs[0] != "a:1" || addrs[1] != "b:1" {
+			t.Error(it, addrs, err)
+		}
+	}
+	if _, err := parseAddrs([]byte("[1,")); err == nil {
+		t.Error("no error")
+	}
+}
+
+func TestNewDiscovery(t *testing.T) {
+	if _, err := NewDiscovery("s", DiscoveryConfig{Type: "unknown", Target: "t"}, nil); err == nil {
+		t.Error("unknown type")
+	}
+	if _, err := NewDiscovery("s", DiscoveryConfig{Type: DiscoveryHttp}, nil); err == nil {
+		t.Error("empty target")
+	}
+	if _, err := NewDiscovery("s", DiscoveryConfig{Type: DiscoveryDns, Target: "localhost"}, nil); err == nil {
+		t.Error("empty port")
+	}
+
+	d, err := NewDiscovery("s", DiscoveryConfig{}, []string{"a:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &testUpdate{}
+	d.Start(u.update)
+	defer d.Stop()
+	if last := u.wait("a:1"); last != nil {
+		t.Error(last)
+	}
+}
+
+func TestFileDiscovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discovery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "addrs")
+
+	d, err := newPollDiscovery("s", DiscoveryConfig{Type: DiscoveryFile, Target: file}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.interval = 10 * time.Millisecond
+	u := &testUpdate{}
+	d.Start(u.update) //the file do not exist, keep running
+	defer d.Stop()
+
+	if err = ioutil.WriteFile(file, []byte("a:1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if last := u.wait("a:1"); last != nil {
+		t.Fatal(last)
+	}
+	next := time.Now().Add(time.Second) //the modification time must be changed
+	if err = ioutil.WriteFile(file, []byte(`["a:2","a:3"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Chtimes(file, next, next)
+	if last := u.wait("a:2", "a:3"); last != nil {
+		t.Fatal(last)
+	}
+
+	_ = os.Remove(file) //error, keep the last addresses
+	time.Sleep(50 * time.Millisecond)
+	if last := u.wait("a:2", "a:3"); last != nil {
+		t.Error(last)
+	}
+}
+
+func TestHttpDiscovery(t *testing.T) {
+	var mutex sync.Mutex
+	body, status := `{"addrs":["a:1"]}`, http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	d, err := newPollDiscovery("s", DiscoveryConfig{Type: DiscoveryHttp, Target: server.URL}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.interval = 10 * time.Millisecond
+	u := &testUpdate{}
+	d.Start(u.update)
+	defer d.Stop()
+	if last := u.wait("a:1"); last != nil {
+		t.Fatal(last)
+	}
+
+	mutex.Lock()
+	status = http.StatusInternalServerError
+	mutex.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	mutex.Lock()
+	body, status = `["b:1"]`, http.StatusOK
+	mutex.Unlock()
+	if last := u.wait("b:1"); last != nil {
+		t.Fatal(last)
+	}
+	u.mutex.Lock()
+	count := u.count
+	u.mutex.Unlock()
+	if count != 2 {
+		t.Error(count)
+	}
+}
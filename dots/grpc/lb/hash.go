@@ -0,0 +1,102 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package lb
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/metadata"
+)
+
+//the virtual nodes of every address in the hash ring
+const hashReplicas = 100
+
+func init() {
+	balancer.Register(withStates(base.NewBalancerBuilderV2(Hash, &hashPickerBuilder{}, base.Config{HealthCheck: true})))
+}
+
+type hashPickerBuilder struct{}
+
+func (c *hashPickerBuilder) Build(info base.PickerBuildInfo) balancer.V2Picker {
+	grpclog.Infof("hashPicker: newPicker called with readySCs: %v", info.ReadySCs)
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPickerV2(balancer.ErrNoSubConnAvailable)
+	}
+	p := &hashPicker{
+		subConns: make([]balancer.SubConn, 0, len(info.ReadySCs)),
+		ring:     make([]hashNode, 0, len(info.ReadySCs)*hashReplicas),
+	}
+	for sc, it := range info.ReadySCs {
+		p.hashKey = balanceAttrOf(it.Address).hashKey
+		for i := 0; i < hashReplicas; i++ {
+			p.ring = append(p.ring, hashNode{
+				hash:  crc32.ChecksumIEEE([]byte(it.Address.Addr + "#" + strconv.Itoa(i))),
+				index: len(p.subConns),
+			})
+		}
+		p.subConns = append(p.subConns, sc)
+	}
+	sort.Slice(p.ring, func(i, j int) bool {
+		return p.ring[i].hash < p.ring[j].hash
+	})
+	return withBreakers(info, p)
+}
+
+type hashNode struct {
+	hash  uint32
+	index int //index of subConns
+}
+
+//the same value of the metadata key is picked the same address, if the addresses do not change
+//if the request has no the metadata key, pick in turn
+type hashPicker struct {
+	subConns []balancer.SubConn
+	ring     []hashNode //sorted by hash
+	hashKey  string
+
+	mu   sync.Mutex
+	next int
+}
+
+func (c *hashPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	return c.pickExcept(info, nil)
+}
+
+//walk along the ring from the hash, skip the excepted sub connections
+func (c *hashPicker) pickExcept(info balancer.PickInfo, except map[balancer.SubConn]bool) (balancer.PickResult, error) {
+	var values []string
+	if md, ok := metadata.FromOutgoingContext(info.Ctx); ok {
+		values = md.Get(c.hashKey)
+	}
+	if len(values) < 1 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for n := 0; n < len(c.subConns); n++ {
+			sc := c.subConns[c.next]
+			c.next = (c.next + 1) % len(c.subConns)
+			if !except[sc] {
+				return balancer.PickResult{SubConn: sc}, nil
+			}
+		}
+		return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
+	}
+
+	h := crc32.ChecksumIEEE([]byte(values[0]))
+	i := sort.Search(len(c.ring), func(i int) bool {
+		return c.ring[i].hash >= h
+	})
+	for n := 0; n < len(c.ring); n++ {
+		sc := c.subConns[c.ring[(i+n)%len(c.ring)].index]
+		if !except[sc] {
+			return balancer.PickResult{SubConn: sc}, nil
+		}
+	}
+	return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
+}
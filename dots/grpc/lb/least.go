@@ -0,0 +1,116 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package lb
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/grpclog"
+)
+
+func init() {
+	balancer.Register(withStates(base.NewBalancerBuilderV2(Least, &leastPickerBuilder{}, base.Config{HealthCheck: true})))
+	balancer.Register(withStates(base.NewBalancerBuilderV2(P2c, &leastPickerBuilder{p2c: true, r: rand.New(rand.NewSource(time.Now().UnixNano()))}, base.Config{HealthCheck: true})))
+}
+
+//the outstanding requests are counted by the picker, when the picker is rebuilt, they begin from zero
+type leastPickerBuilder struct {
+	p2c       bool
+	r         *rand.Rand
+	randMutex sync.Mutex
+}
+
+func (c *leastPickerBuilder) Build(info base.PickerBuildInfo) balancer.V2Picker {
+	grpclog.Infof("leastPicker: newPicker called with readySCs: %v", info.ReadySCs)
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPickerV2(balancer.ErrNoSubConnAvailable)
+	}
+	p := &leastPicker{
+		subConns:    make([]balancer.SubConn, 0, len(info.ReadySCs)),
+		outstanding: make([]int64, len(info.ReadySCs)),
+	}
+	for sc := range info.ReadySCs {
+		p.subConns = append(p.subConns, sc)
+	}
+	if c.p2c {
+		p.r = c.r
+		p.randMutex = &c.randMutex
+	}
+	return withBreakers(info, p)
+}
+
+type leastPicker struct {
+	subConns    []balancer.SubConn
+	outstanding []int64 //atomic
+
+	//only for p2c, shared by the pickers of the builder
+	r         *rand.Rand
+	randMutex *sync.Mutex
+
+	mu   sync.Mutex
+	next int //start index of least, so the same outstanding requests are picked in turn
+}
+
+func (c *leastPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	return c.pickExcept(info, nil)
+}
+
+//pick from the sub connections that are not excepted
+func (c *leastPicker) pickExcept(info balancer.PickInfo, except map[balancer.SubConn]bool) (balancer.PickResult, error) {
+	index := -1
+	if c.r != nil && len(c.subConns) > 1 {
+		indexes := make([]int, 0, len(c.subConns))
+		for i, sc := range c.subConns {
+			if !except[sc] {
+				indexes = append(indexes, i)
+			}
+		}
+		if len(indexes) == 1 {
+			index = indexes[0]
+		} else if len(indexes) > 1 {
+			c.randMutex.Lock()
+			a := c.r.Intn(len(indexes))
+			b := c.r.Intn(len(indexes) - 1)
+			c.randMutex.Unlock()
+			if b >= a { //two different
+				b++
+			}
+			a, b = indexes[a], indexes[b]
+			index = a
+			if atomic.LoadInt64(&c.outstanding[b]) < atomic.LoadInt64(&c.outstanding[a]) {
+				index = b
+			}
+		}
+	} else {
+		c.mu.Lock()
+		start := c.next
+		c.next = (c.next + 1) % len(c.subConns)
+		c.mu.Unlock()
+		for i := 0; i < len(c.subConns); i++ {
+			n := (start + i) % len(c.subConns)
+			if except[c.subConns[n]] {
+				continue
+			}
+			if index < 0 || atomic.LoadInt64(&c.outstanding[n]) < atomic.LoadInt64(&c.outstanding[index]) {
+				index = n
+			}
+		}
+	}
+	if index < 0 {
+		return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
+	}
+
+	atomic.AddInt64(&c.outstanding[index], 1)
+	return balancer.PickResult{
+		SubConn: c.subConns[index],
+		Done: func(balancer.DoneInfo) {
+			atomic.AddInt64(&c.outstanding[index], -1)
+		},
+	}, nil
+}
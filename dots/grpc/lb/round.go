@@ -4,11 +4,9 @@
 package lb
 
 import (
-	"context"
 	"google.golang.org/grpc/balancer"
 	"google.golang.org/grpc/balancer/base"
 	"google.golang.org/grpc/grpclog"
-	"google.golang.org/grpc/resolver"
 	"math/rand"
 	"sync"
 	"time"
@@ -19,11 +17,11 @@ const Name = Round
 
 // newBuilder creates a new roundrobin balancer builder.
 func newBuilder() balancer.Builder {
-	return base.NewBalancerBuilderWithConfig(Name, &rrPickerBuilder{r: rand.New(rand.NewSource(time.Now().UnixNano()))}, base.Config{HealthCheck: true})
+	return base.NewBalancerBuilderV2(Name, &rrPickerBuilder{r: rand.New(rand.NewSource(time.Now().UnixNano()))}, base.Config{HealthCheck: true})
 }
 
 func init() {
-	balancer.Register(newBuilder())
+	balancer.Register(withStates(newBuilder()))
 }
 
 type rrPickerBuilder struct {
@@ -39,22 +37,22 @@ func (c *rrPickerBuilder) Intn(n int) int {
 	return res
 }
 
-func (c *rrPickerBuilder) Build(readySCs map[resolver.Address]balancer.SubConn) balancer.Picker {
-	grpclog.Infof("roundrobinPicker: newPicker called with readySCs: %v", readySCs)
-	if len(readySCs) == 0 {
-		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+func (c *rrPickerBuilder) Build(info base.PickerBuildInfo) balancer.V2Picker {
+	grpclog.Infof("roundrobinPicker: newPicker called with readySCs: %v", info.ReadySCs)
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPickerV2(balancer.ErrNoSubConnAvailable)
 	}
 	var scs []balancer.SubConn
-	for _, sc := range readySCs {
+	for sc := range info.ReadySCs {
 		scs = append(scs, sc)
 	}
-	return &rrPicker{
+	return withBreakers(info, &rrPicker{
 		subConns: scs,
 		// Start at a random index, as the same RR balancer rebuilds a new
 		// picker when SubConn states change, and we don't want to apply excess
 		// load to the first server in the list.
 		next: c.Intn(len(scs)),
-	}
+	})
 }
 
 type rrPicker struct {
@@ -67,10 +65,20 @@ type rrPicker struct {
 	next int
 }
 
-func (c *rrPicker) Pick(ctx context.Context, opts balancer.PickOptions) (balancer.SubConn, func(balancer.DoneInfo), error) {
+func (c *rrPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	return c.pickExcept(info, nil)
+}
+
+//pick in turn, skip the excepted sub connections
+func (c *rrPicker) pickExcept(info balancer.PickInfo, except map[balancer.SubConn]bool) (balancer.PickResult, error) {
 	c.mu.Lock()
-	sc := c.subConns[c.next]
-	c.next = (c.next + 1) % len(c.subConns)
-	c.mu.Unlock()
-	return sc, nil, nil
+	defer c.mu.Unlock()
+	for n := 0; n < len(c.subConns); n++ {
+		sc := c.subConns[c.next]
+		c.next = (c.next + 1) % len(c.subConns)
+		if !except[sc] {
+			return balancer.PickResult{SubConn: sc}, nil
+		}
+	}
+	return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
 }
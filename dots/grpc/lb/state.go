@@ -0,0 +1,134 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package lb
+
+import (
+	"sync"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/resolver"
+)
+
+//AddrStates the connectivity states of the addresses of one service, the balancers update them
+//First balance do not support it
+type AddrStates struct {
+	mutex    sync.Mutex
+	states   map[string]connectivity.State //key: address
+	onChange func(addr string, state connectivity.State)
+}
+
+//NewAddrStates the onChange is called when the state of an address is changed, it can be nil
+func NewAddrStates(onChange func(addr string, state connectivity.State)) *AddrStates {
+	return &AddrStates{
+		states:   make(map[string]connectivity.State),
+		onChange: onChange,
+	}
+}
+
+//States return the states of the current addresses
+func (c *AddrStates) States() map[string]connectivity.State {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	res := make(map[string]connectivity.State, len(c.states))
+	for addr, it := range c.states {
+		res[addr] = it
+	}
+	return res
+}
+
+func (c *AddrStates) set(addr string, state connectivity.State) {
+	c.mutex.Lock()
+	old, ok := c.states[addr]
+	if state == connectivity.Shutdown {
+		delete(c.states, addr)
+	} else {
+		c.states[addr] = state
+	}
+	c.mutex.Unlock()
+	if (!ok || old != state) && c.onChange != nil {
+		c.onChange(addr, state)
+	}
+}
+
+//wrap the balancer builder, record the states of the sub connections into the AddrStates of the addresses
+func withStates(b balancer.Builder) balancer.Builder {
+	return &stateBuilder{Builder: b}
+}
+
+type stateBuilder struct {
+	balancer.Builder
+}
+
+func (c *stateBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	sc := &stateClientConn{ClientConn: cc, addrs: make(map[balancer.SubConn]resolver.Address)}
+	return &stateBalancer{Balancer: c.Builder.Build(sc, opts), cc: sc}
+}
+
+type stateClientConn struct {
+	balancer.ClientConn
+	mutex sync.Mutex
+	addrs map[balancer.SubConn]resolver.Address
+}
+
+func (c *stateClientConn) NewSubConn(addrs []resolver.Address, opts balancer.NewSubConnOptions) (balancer.SubConn, error) {
+	sc, err := c.ClientConn.NewSubConn(addrs, opts)
+	if err == nil && len(addrs) > 0 {
+		c.mutex.Lock()
+		c.addrs[sc] = addrs[0]
+		c.mutex.Unlock()
+	}
+	return sc, err
+}
+
+//update the state, the sub connection is removed when its state is shutdown
+func (c *stateClientConn) update(sc balancer.SubConn, state connectivity.State) {
+	c.mutex.Lock()
+	addr, ok := c.addrs[sc]
+	if state == connectivity.Shutdown {
+		delete(c.addrs, sc)
+	}
+	c.mutex.Unlock()
+	if !ok {
+		return
+	}
+	if states := balanceAttrOf(addr).states; states != nil {
+		states.set(addr.Addr, state)
+	}
+}
+
+type stateBalancer struct {
+	balancer.Balancer
+	cc *stateClientConn
+}
+
+func (c *stateBalancer) HandleSubConnStateChange(sc balancer.SubConn, state connectivity.State) {
+	c.cc.update(sc, state)
+	c.Balancer.HandleSubConnStateChange(sc, state)
+}
+
+func (c *stateBalancer) UpdateClientConnState(s balancer.ClientConnState) error {
+	if b, ok := c.Balancer.(balancer.V2Balancer); ok {
+		return b.UpdateClientConnState(s)
+	}
+	c.Balancer.HandleResolvedAddrs(s.ResolverState.Addresses, nil)
+	return nil
+}
+
+func (c *stateBalancer) ResolverError(err error) {
+	if b, ok := c.Balancer.(balancer.V2Balancer); ok {
+		b.ResolverError(err)
+		return
+	}
+	c.Balancer.HandleResolvedAddrs(nil, err)
+}
+
+func (c *stateBalancer) UpdateSubConnState(sc balancer.SubConn, s balancer.SubConnState) {
+	c.cc.update(sc, s.ConnectivityState)
+	if b, ok := c.Balancer.(balancer.V2Balancer); ok {
+		b.UpdateSubConnState(sc, s)
+		return
+	}
+	c.Balancer.HandleSubConnStateChange(sc, s.ConnectivityState)
+}
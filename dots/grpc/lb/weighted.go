@@ -0,0 +1,74 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package lb
+
+import (
+	"sync"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/grpclog"
+)
+
+func init() {
+	balancer.Register(withStates(base.NewBalancerBuilderV2(Weighted, &wrrPickerBuilder{}, base.Config{HealthCheck: true})))
+}
+
+type wrrPickerBuilder struct{}
+
+func (c *wrrPickerBuilder) Build(info base.PickerBuildInfo) balancer.V2Picker {
+	grpclog.Infof("weightedPicker: newPicker called with readySCs: %v", info.ReadySCs)
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPickerV2(balancer.ErrNoSubConnAvailable)
+	}
+	p := &wrrPicker{
+		subConns: make([]balancer.SubConn, 0, len(info.ReadySCs)),
+		weights:  make([]int, 0, len(info.ReadySCs)),
+	}
+	for sc, it := range info.ReadySCs {
+		p.subConns = append(p.subConns, sc)
+		p.weights = append(p.weights, balanceAttrOf(it.Address).weight)
+		p.total += p.weights[len(p.weights)-1]
+	}
+	p.current = make([]int, len(p.subConns))
+	return withBreakers(info, p)
+}
+
+//smooth weighted round robin, the same as nginx
+//every pick, current += weight, pick the max current, then the max current -= total
+type wrrPicker struct {
+	subConns []balancer.SubConn
+	weights  []int
+	total    int
+
+	mu      sync.Mutex
+	current []int
+}
+
+func (c *wrrPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	return c.pickExcept(info, nil)
+}
+
+//the excepted sub connections are skipped, and their weights are not in the total, the same as the down peers of nginx
+func (c *wrrPicker) pickExcept(info balancer.PickInfo, except map[balancer.SubConn]bool) (balancer.PickResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	best := -1
+	total := 0
+	for i := range c.current {
+		if except[c.subConns[i]] {
+			continue
+		}
+		c.current[i] += c.weights[i]
+		total += c.weights[i]
+		if best < 0 || c.current[i] > c.current[best] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return balancer.PickResult{}, balancer.ErrNoSubConnAvailable
+	}
+	c.current[best] -= total
+	return balancer.PickResult{SubConn: c.subConns[best]}, nil
+}
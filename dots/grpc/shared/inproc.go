@@ -0,0 +1,97 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package shared
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+//InprocPrefix the address of the in process(memory) listener, sample: "inproc://hi"
+//the server and the client must be in the same process, no socket and no port
+const InprocPrefix = "inproc://"
+
+//the buffer size of every direction of the connection
+const inprocBufferSize = 1024 * 1024
+
+var inprocListeners = struct {
+	sync.Mutex
+	listeners map[string]*inprocListener
+}{listeners: make(map[string]*inprocListener)}
+
+type inprocListener struct {
+	*bufconn.Listener
+	name string
+	once sync.Once
+}
+
+//Close close the listener and remove the name, then the name can be listened again
+func (c *inprocListener) Close() error {
+	var err error
+	c.once.Do(func() {
+		inprocListeners.Lock()
+		if inprocListeners.listeners[c.name] == c {
+			delete(inprocListeners.listeners, c.name)
+		}
+		inprocListeners.Unlock()
+		err = c.Listener.Close()
+	})
+	return err
+}
+
+func (c *inprocListener) Addr() net.Addr {
+	return inprocAddr(c.name)
+}
+
+type inprocAddr string
+
+func (c inprocAddr) Network() string { return "inproc" }
+func (c inprocAddr) String() string  { return InprocPrefix + string(c) }
+
+//IsInproc return true if the address is the in process address
+func IsInproc(addr string) bool {
+	return strings.HasPrefix(addr, InprocPrefix)
+}
+
+//Listen listen the tcp address or the in process address("inproc://name")
+func Listen(addr string) (net.Listener, error) {
+	if !IsInproc(addr) {
+		lis, err := net.Listen("tcp", addr)
+		return lis, errors.WithStack(err)
+	}
+	name := addr[len(InprocPrefix):]
+	if len(name) < 1 {
+		return nil, errors.New("inproc: no name of the address: " + addr)
+	}
+	inprocListeners.Lock()
+	defer inprocListeners.Unlock()
+	if _, ok := inprocListeners.listeners[name]; ok {
+		return nil, errors.New("inproc: the address is in use: " + addr)
+	}
+	lis := &inprocListener{Listener: bufconn.Listen(inprocBufferSize), name: name}
+	inprocListeners.listeners[name] = lis
+	return lis, nil
+}
+
+//Dial dial the tcp address or the in process address, it is the dialer of grpc(grpc.WithContextDialer)
+func Dial(ctx context.Context, addr string) (net.Conn, error) {
+	if !IsInproc(addr) {
+		return (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	inprocListeners.Lock()
+	lis, ok := inprocListeners.listeners[addr[len(InprocPrefix):]]
+	inprocListeners.Unlock()
+	if !ok {
+		return nil, errors.New("inproc: no listener of the address: " + addr)
+	}
+	return lis.Dial()
+}
@@ -16,9 +16,10 @@ import (
 )
 
 var (
-	_ dot.Lifer    = (*lineImp)(nil)
-	_ dot.Line     = (*lineImp)(nil)
-	_ dot.Injecter = (*lineImp)(nil)
+	_ dot.Lifer     = (*lineImp)(nil)
+	_ dot.Line      = (*lineImp)(nil)
+	_ dot.LivesLine = (*lineImp)(nil)
+	_ dot.Injecter  = (*lineImp)(nil)
 )
 
 type lineImp struct {
@@ -153,6 +154,12 @@ func (c *lineImp) PreAdd(typeLives ...*dot.TypeLives) error {
 	return err
 }
 
+//Lives see dot.LivesLine
+func (c *lineImp) Lives() []*dot.Live {
+	order, circle := c.RelyOrder()
+	return append(order, circle...)
+}
+
 func (c *lineImp) RelyOrder() ([]*dot.Live, []*dot.Live) {
 
 	var cloneLives map[dot.LiveId]*dot.Live
@@ -557,7 +564,9 @@ func (c *lineImp) Inject(obj interface{}) error {
 
 		var d dot.Dot
 		{
-			if len(tname) < 1 { //by type
+			if injecter, name, ok := dot.GetTagInjecter(tname); ok { //by the registered injecter, sample: `dot:"grpc:hi"`
+				d, err2 = injecter(c, f.Type(), name)
+			} else if len(tname) < 1 { //by type
 				d, err2 = c.GetByType(f.Type())
 			} else { //by liveid
 				d, err2 = c.GetByLiveId(dot.LiveId(tname))
@@ -628,16 +637,20 @@ func (c *lineImp) injectInLine(obj interface{}, live *dot.Live) error {
 
 		var d dot.Dot
 		{
-			if len(live.RelyLives) > 0 { //Config prior
-				if lid, ok := live.RelyLives[tField.Name]; ok {
-					d, errt2 = c.GetByLiveId(dot.LiveId(lid))
+			if injecter, name, ok := dot.GetTagInjecter(tname); ok { //by the registered injecter, sample: `dot:"grpc:hi"`
+				d, errt2 = injecter(c, f.Type(), name)
+			} else {
+				if len(live.RelyLives) > 0 { //Config prior
+					if lid, ok := live.RelyLives[tField.Name]; ok {
+						d, errt2 = c.GetByLiveId(dot.LiveId(lid))
+					}
 				}
-			}
-			if d == nil {
-				if len(tname) < 1 { //by type
-					d, errt2 = c.GetByType(f.Type())
-				} else { //by liveid
-					d, errt2 = c.GetByLiveId(dot.LiveId(tname))
+				if d == nil {
+					if len(tname) < 1 { //by type
+						d, errt2 = c.GetByType(f.Type())
+					} else { //by liveid
+						d, errt2 = c.GetByLiveId(dot.LiveId(tname))
+					}
 				}
 			}
 
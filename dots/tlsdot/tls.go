@@ -0,0 +1,101 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package tlsdot
+
+import (
+	"github.com/scryinfo/dot/dot"
+	"github.com/scryinfo/scryg/sutils/sfile"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+)
+
+const (
+	TlsModeNone   = "none"   //no tls
+	TlsModeServer = "server" //only the server certificate is verified
+	TlsModeMutual = "mutual" //both the server and the client certificates are verified
+)
+
+//TlsConfig
+//if the Mode is empty, it is decided by which files are not empty:
+//case the CaPem Pem Key are not empty, both tls
+//case only the Pem is not empty, server tls,  if the ServerNameOverride exist, then set the ServerNameOverride that it make the pam and key
+//the invalid combinations are reported when the dot is created, see Tls
+type TlsConfig struct {
+	//public key of ca
+	CaPem string `json:"caPem"`
+	//public key of client or server
+	Pem string `json:"pem"`
+	//private key of client
+	Key string `json:"key"`
+	//if the CaPam and Key are empty, set the ServerNameOverride is the name of the pem
+	ServerNameOverride string `json:"serverNameOverride"`
+
+	//"none", "server" or "mutual"
+	Mode string `json:"mode"`
+	//"1.0", "1.1", "1.2" or "1.3", the default is the default of go
+	MinVersion string `json:"minVersion"`
+	//the names of the allowed cipher suites, sample: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", the default is the default of go
+	//the cipher suites of tls 1.3 can not be configured
+	CipherSuites []string `json:"cipherSuites"`
+	//the protocols of alpn, the default of the server is ["h2", "http/1.1"]
+	Alpn []string `json:"alpn"`
+	//server only, the certificates chosen by the sni of the client, the Pem and Key are used if no one is matched
+	SniCerts []TlsCert `json:"sniCerts"`
+	//the crl file(made by dots/certificate), it must be signed by the CaPem; the peer certificates in it are rejected
+	Crl string `json:"crl"`
+	//the serial numbers of the revoked peer certificates, decimal or hex with prefix "0x"
+	RevokedSerials []string `json:"revokedSerials"`
+	//merge the system root pool with the CaPem
+	SystemRoots bool `json:"systemRoots"`
+}
+
+//TlsCert the server certificate chosen by sni
+type TlsCert struct {
+	Pem string `json:"pem"`
+	Key string `json:"key"`
+	//sample: "a.scry.info", "*.scry.info"; if it is empty, use the dns names of the certificate
+	ServerNames []string `json:"serverNames"`
+}
+
+//GetFullPathFile if the file is not abs path, preferred to use the executable path, then the current path, "" if the file is not found
+func GetFullPathFile(file string) string {
+	if filepath.IsAbs(file) {
+		return file
+	}
+
+	res := ""
+	for {
+		ex, err := os.Executable()
+		if err != nil {
+			dot.Logger().Errorln("Tls", zap.Error(err))
+			res = ""
+			break
+		}
+
+		ex = filepath.Dir(ex)
+		temp := filepath.Join(ex, file)
+		if sfile.ExistFile(temp) {
+			res = temp
+			break
+		} else { //try find file from the current path
+			temp, err = os.Getwd()
+			if err != nil {
+				dot.Logger().Errorln("Tls", zap.Error(err))
+				res = ""
+				break
+			}
+			temp = filepath.Join(temp, file)
+			if sfile.ExistFile(temp) {
+				res = temp
+				break
+			}
+		}
+
+		break
+	}
+
+	return res
+
+}
@@ -0,0 +1,661 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package tlsdot
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/scryinfo/dot/dot"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/credentials"
+)
+
+//the files are checked at most once per interval when they are used(handshake)
+var tlsReloadInterval = time.Second
+
+//Tls make the *tls.Config or credentials from the TlsConfig,
+//the key pairs and the ca pools are loaded by callbacks(GetCertificate, GetClientCertificate, GetConfigForClient and the client handshake),
+//so they are reloaded when the files change, and the connections after that use the new ones
+//the same files are loaded once, and shared by all users of the Tls
+//one line has one Tls(see Get), the dots inject it by the tag `dot:""`
+type Tls struct {
+	mutex sync.Mutex
+	pairs map[string]*tlsKeyPair //key: pem + "|" + key
+	pools map[string]*tlsCaPool  //key: ca pem + "|" + system roots
+	crls  map[string]*tlsCrl     //key: crl + "|" + ca pem
+}
+
+//New return a Tls that is not in any line, sample: the tests
+func New() *Tls {
+	return &Tls{
+		pairs: make(map[string]*tlsKeyPair),
+		pools: make(map[string]*tlsCaPool),
+		crls:  make(map[string]*tlsCrl),
+	}
+}
+
+var getMutex sync.Mutex
+
+//Get return the Tls of the line, it is made and added to the line(by type) at the first call,
+//the injection is after the Create of all dots, so the dots that use the Tls in Create call Get, and the other dots inject it by `dot:""`
+//if the l is nil, return a new one
+func Get(l dot.Line) *Tls {
+	if l == nil {
+		return New()
+	}
+	getMutex.Lock()
+	defer getMutex.Unlock()
+	injecter := l.ToInjecter()
+	if d, err := injecter.GetByType(reflect.TypeOf((*Tls)(nil))); err == nil {
+		if c, ok := d.(*Tls); ok && c != nil {
+			return c
+		}
+	}
+	c := New()
+	_ = injecter.ReplaceOrAddByType(c)
+	return c
+}
+
+//ServerConfig return nil if the mode is none
+//the client certificate is required and verified in the mutual mode
+func (c *Tls) ServerConfig(conf TlsConfig) (*tls.Config, error) {
+	mode, err := serverTlsMode(conf)
+	if err != nil || mode == TlsModeNone {
+		return nil, err
+	}
+	opts, err := c.options(conf)
+	if err != nil {
+		return nil, err
+	}
+	certs := &tlsServerCerts{}
+	if certs.def, err = c.keyPair(conf.Pem, conf.Key); err != nil {
+		return nil, err
+	}
+	for _, it := range conf.SniCerts {
+		pair, err := c.keyPair(it.Pem, it.Key)
+		if err != nil {
+			return nil, err
+		}
+		certs.sni = append(certs.sni, tlsSniCert{names: it.ServerNames, pair: pair})
+	}
+
+	makeConfig := func(cas *x509.CertPool) *tls.Config {
+		res := &tls.Config{
+			GetCertificate: certs.get,
+			MinVersion:     opts.minVersion,
+			CipherSuites:   opts.ciphers,
+			NextProtos:     opts.alpn,
+		}
+		if cas != nil {
+			res.ClientCAs = cas
+			res.ClientAuth = tls.RequireAndVerifyClientCert
+			if opts.revoked != nil {
+				res.VerifyPeerCertificate = opts.revoked.verify
+			}
+		}
+		return res
+	}
+	if mode == TlsModeServer {
+		return makeConfig(nil), nil
+	}
+
+	pool, err := c.caPool(conf.CaPem, conf.SystemRoots)
+	if err != nil {
+		return nil, err
+	}
+	cas, _ := pool.get()
+	res := makeConfig(cas)
+	//the config of the handshake replaces the whole config, so the ca pool is reloaded
+	res.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cas, err := pool.get()
+		if err != nil {
+			return nil, err
+		}
+		return makeConfig(cas), nil
+	}
+	return res, nil
+}
+
+//ClientCredentials return nil if the mode is none
+//the CaPem is the root, if it is empty, the Pem is the root in the server mode(the old way)
+//the Pem and Key are the client certificate in the mutual mode
+func (c *Tls) ClientCredentials(conf TlsConfig) (credentials.TransportCredentials, error) {
+	mode, err := clientTlsMode(conf)
+	if err != nil || mode == TlsModeNone {
+		return nil, err
+	}
+	creds := &tlsClientCreds{serverName: conf.ServerNameOverride}
+	if creds.opts, err = c.options(conf); err != nil {
+		return nil, err
+	}
+	root := conf.CaPem
+	if mode == TlsModeServer && len(root) < 1 {
+		root = conf.Pem
+	}
+	if creds.pool, err = c.caPool(root, conf.SystemRoots); err != nil {
+		return nil, err
+	}
+	if mode == TlsModeMutual {
+		if creds.pair, err = c.keyPair(conf.Pem, conf.Key); err != nil {
+			return nil, err
+		}
+	}
+	return creds, nil
+}
+
+func serverTlsMode(conf TlsConfig) (string, error) {
+	mode := conf.Mode
+	switch mode {
+	case "":
+		switch {
+		case len(conf.Pem) < 1 || len(conf.Key) < 1:
+			return TlsModeNone, nil
+		case len(conf.CaPem) > 0:
+			mode = TlsModeMutual
+		default:
+			mode = TlsModeServer
+		}
+	case TlsModeNone:
+		return mode, conf.checkNone()
+	case TlsModeServer, TlsModeMutual:
+		if len(conf.Pem) < 1 || len(conf.Key) < 1 {
+			return "", errors.New("tls: the pem and key of the server are needed in the mode: " + mode)
+		}
+	default:
+		return "", errors.New("tls: not supported mode: " + mode)
+	}
+
+	verifyClient := len(conf.CaPem) > 0 || conf.SystemRoots || len(conf.Crl) > 0 || len(conf.RevokedSerials) > 0
+	switch {
+	case mode == TlsModeServer && verifyClient:
+		return "", errors.New("tls: the caPem, systemRoots, crl and revokedSerials verify the client certificate, they are used in the mutual mode only")
+	case mode == TlsModeMutual && len(conf.CaPem) < 1 && !conf.SystemRoots:
+		return "", errors.New("tls: the caPem or systemRoots is needed to verify the client certificate")
+	}
+	return mode, nil
+}
+
+func clientTlsMode(conf TlsConfig) (string, error) {
+	mode := conf.Mode
+	switch mode {
+	case "":
+		switch {
+		case len(conf.CaPem) > 0 && len(conf.Pem) > 0 && len(conf.Key) > 0:
+			mode = TlsModeMutual
+		case len(conf.Pem) > 0:
+			mode = TlsModeServer
+		default:
+			return TlsModeNone, nil
+		}
+	case TlsModeNone:
+		return mode, conf.checkNone()
+	case TlsModeServer:
+		switch {
+		case len(conf.Key) > 0:
+			return "", errors.New("tls: the key of the client certificate is used in the mutual mode only")
+		case len(conf.CaPem) > 0 && len(conf.Pem) > 0:
+			return "", errors.New("tls: the pem is the root when the caPem is empty, do not set both of them in the server mode")
+		case len(conf.CaPem) < 1 && len(conf.Pem) < 1 && !conf.SystemRoots:
+			return "", errors.New("tls: the caPem, pem or systemRoots is needed to verify the server certificate")
+		}
+	case TlsModeMutual:
+		switch {
+		case len(conf.Pem) < 1 || len(conf.Key) < 1:
+			return "", errors.New("tls: the pem and key of the client are needed in the mutual mode")
+		case len(conf.CaPem) < 1 && !conf.SystemRoots:
+			return "", errors.New("tls: the caPem or systemRoots is needed to verify the server certificate")
+		}
+	default:
+		return "", errors.New("tls: not supported mode: " + mode)
+	}
+	if len(conf.SniCerts) > 0 {
+		return "", errors.New("tls: the sniCerts are used by the server only")
+	}
+	return mode, nil
+}
+
+func (c *TlsConfig) checkNone() error {
+	if len(c.CaPem) > 0 || len(c.Pem) > 0 || len(c.Key) > 0 || len(c.SniCerts) > 0 || len(c.Crl) > 0 || len(c.RevokedSerials) > 0 {
+		return errors.New("tls: the mode is none, but the certificates are not empty")
+	}
+	return nil
+}
+
+var tlsVersions = map[string]uint16{
+	"":    0,
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCipherSuites = map[string]uint16{
+	"TLS_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":   tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384": tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305":    tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305":  tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+}
+
+//the options of both the server and client
+type tlsOptions struct {
+	minVersion uint16
+	ciphers    []uint16
+	alpn       []string
+	revoked    *tlsRevoked //nil if no crl and revoked serials
+}
+
+func (c *Tls) options(conf TlsConfig) (*tlsOptions, error) {
+	res := &tlsOptions{alpn: conf.Alpn}
+	ok := false
+	if res.minVersion, ok = tlsVersions[conf.MinVersion]; !ok {
+		return nil, errors.New("tls: not supported min version: " + conf.MinVersion)
+	}
+	for _, it := range conf.CipherSuites {
+		id, ok := tlsCipherSuites[it]
+		if !ok {
+			return nil, errors.New("tls: not supported cipher suite: " + it)
+		}
+		res.ciphers = append(res.ciphers, id)
+	}
+	if len(res.ciphers) > 0 && res.minVersion == tls.VersionTLS13 {
+		return nil, errors.New("tls: the cipher suites of tls 1.3 can not be configured")
+	}
+	if len(res.alpn) < 1 {
+		res.alpn = []string{"h2", "http/1.1"}
+	}
+
+	if len(conf.Crl) > 0 || len(conf.RevokedSerials) > 0 {
+		res.revoked = &tlsRevoked{serials: make(map[string]bool, len(conf.RevokedSerials))}
+		for _, it := range conf.RevokedSerials {
+			serial, ok := new(big.Int), false
+			if strings.HasPrefix(it, "0x") || strings.HasPrefix(it, "0X") {
+				serial, ok = serial.SetString(it[2:], 16)
+			} else {
+				serial, ok = serial.SetString(it, 10)
+			}
+			if !ok {
+				return nil, errors.New("tls: the revoked serial is invalid: " + it)
+			}
+			res.revoked.serials[serial.String()] = true
+		}
+		if len(conf.Crl) > 0 {
+			if len(conf.CaPem) < 1 {
+				return nil, errors.New("tls: the caPem is needed to verify the crl")
+			}
+			var err error
+			if res.revoked.crl, err = c.crl(conf.Crl, conf.CaPem); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
+
+func (c *Tls) keyPair(pemName string, keyName string) (*tlsKeyPair, error) {
+	pemFile := GetFullPathFile(pemName)
+	if len(pemFile) < 1 {
+		return nil, errors.New("the pem is not empty, and can not find the file: " + pemName)
+	}
+	keyFile := GetFullPathFile(keyName)
+	if len(keyFile) < 1 {
+		return nil, errors.New("the key is not empty, and can not find the file: " + keyName)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	p, ok := c.pairs[pemFile+"|"+keyFile]
+	if !ok {
+		p = &tlsKeyPair{tlsFiles{files: []string{pemFile, keyFile}}}
+		c.pairs[pemFile+"|"+keyFile] = p
+	}
+	if _, err := p.get(); err != nil { //the files are wrong at the beginning, it is the error of config
+		return nil, err
+	}
+	return p, nil
+}
+
+//the ca file can be empty if the system roots are used
+func (c *Tls) caPool(ca string, systemRoots bool) (*tlsCaPool, error) {
+	var files []string
+	if len(ca) > 0 {
+		caFile := GetFullPathFile(ca)
+		if len(caFile) < 1 {
+			return nil, errors.New("the ca pem is not empty, and can not find the file: " + ca)
+		}
+		files = append(files, caFile)
+	}
+	key := strings.Join(files, "") + "|" + strconv.FormatBool(systemRoots)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	p, ok := c.pools[key]
+	if !ok {
+		p = &tlsCaPool{tlsFiles: tlsFiles{files: files}, systemRoots: systemRoots}
+		c.pools[key] = p
+	}
+	if _, err := p.get(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (c *Tls) crl(crl string, ca string) (*tlsCrl, error) {
+	crlFile := GetFullPathFile(crl)
+	if len(crlFile) < 1 {
+		return nil, errors.New("the crl is not empty, and can not find the file: " + crl)
+	}
+	caFile := GetFullPathFile(ca)
+	if len(caFile) < 1 {
+		return nil, errors.New("the ca pem is not empty, and can not find the file: " + ca)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	p, ok := c.crls[crlFile+"|"+caFile]
+	if !ok {
+		p = &tlsCrl{tlsFiles{files: []string{crlFile, caFile}}}
+		c.crls[crlFile+"|"+caFile] = p
+	}
+	if _, err := p.get(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+//reload the value when the modification time of the files change
+//if the reloading fail(sample: the pem is replaced, but the key is not yet), keep the old value and try again at the next time
+type tlsFiles struct {
+	mutex   sync.Mutex
+	files   []string
+	modTime time.Time //the latest one of the files
+	checked time.Time
+	value   interface{}
+}
+
+func (c *tlsFiles) load(loader func() (interface{}, error)) (interface{}, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	if c.value != nil && now.Sub(c.checked) < tlsReloadInterval {
+		return c.value, nil
+	}
+	c.checked = now
+
+	var modTime time.Time
+	for _, f := range c.files {
+		info, err := os.Stat(f)
+		if err != nil {
+			if c.value != nil {
+				dot.Logger().Warnln("Tls", zap.String("file", f), zap.Error(err))
+				return c.value, nil
+			}
+			return nil, errors.WithStack(err)
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	if c.value != nil && modTime.Equal(c.modTime) {
+		return c.value, nil
+	}
+
+	v, err := loader()
+	if err != nil {
+		if c.value != nil {
+			dot.Logger().Warnln("Tls", zap.Strings("files", c.files), zap.String("", "reload failed, keep the old one"), zap.Error(err))
+			return c.value, nil
+		}
+		return nil, err
+	}
+	if c.value != nil {
+		dot.Logger().Infoln("Tls", zap.Strings("files", c.files), zap.String("", "reloaded"))
+	}
+	c.value = v
+	c.modTime = modTime
+	return v, nil
+}
+
+type tlsKeyPair struct {
+	tlsFiles
+}
+
+func (c *tlsKeyPair) get() (*tls.Certificate, error) {
+	v, err := c.load(func() (interface{}, error) {
+		cert, err := tls.LoadX509KeyPair(c.files[0], c.files[1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil { //for matching the sni
+			return nil, errors.WithStack(err)
+		}
+		return &cert, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*tls.Certificate), nil
+}
+
+type tlsCaPool struct {
+	tlsFiles
+	systemRoots bool
+}
+
+func (c *tlsCaPool) get() (*x509.CertPool, error) {
+	v, err := c.load(func() (interface{}, error) {
+		pool := x509.NewCertPool()
+		if c.systemRoots {
+			var err error
+			if pool, err = x509.SystemCertPool(); err != nil { //it is a copy
+				return nil, errors.WithStack(err)
+			}
+		}
+		for _, f := range c.files {
+			bs, err := ioutil.ReadFile(f)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if !pool.AppendCertsFromPEM(bs) {
+				return nil, errors.New("credentials: failed to append certificates: " + f)
+			}
+		}
+		return pool, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*x509.CertPool), nil
+}
+
+//the revoked serial numbers of the crl, the crl must be signed by one of the ca
+type tlsCrl struct {
+	tlsFiles
+}
+
+func (c *tlsCrl) get() (map[string]bool, error) {
+	v, err := c.load(func() (interface{}, error) {
+		bs, err := ioutil.ReadFile(c.files[0])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		crl, err := x509.ParseCRL(bs) //pem or der
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if bs, err = ioutil.ReadFile(c.files[1]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		signed := false
+		for block, rest := pem.Decode(bs); block != nil && !signed; block, rest = pem.Decode(rest) {
+			if ca, err := x509.ParseCertificate(block.Bytes); err == nil && ca.CheckCRLSignature(crl) == nil {
+				signed = true
+			}
+		}
+		if !signed {
+			return nil, errors.New("tls: the crl is not signed by the ca: " + c.files[0])
+		}
+		if crl.HasExpired(time.Now()) {
+			dot.Logger().Warnln("Tls", zap.String("crl", c.files[0]), zap.String("", "the crl is expired"))
+		}
+		serials := make(map[string]bool, len(crl.TBSCertList.RevokedCertificates))
+		for _, it := range crl.TBSCertList.RevokedCertificates {
+			serials[it.SerialNumber.String()] = true
+		}
+		return serials, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]bool), nil
+}
+
+//reject the peer certificates that are revoked
+type tlsRevoked struct {
+	serials map[string]bool
+	crl     *tlsCrl //nil if no crl
+}
+
+//tls.Config.VerifyPeerCertificate, it is called after the normal verification
+func (c *tlsRevoked) verify(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	var crl map[string]bool
+	if c.crl != nil {
+		var err error
+		if crl, err = c.crl.get(); err != nil {
+			return err
+		}
+	}
+	for _, chain := range verifiedChains {
+		for _, cert := range chain {
+			if serial := cert.SerialNumber.String(); c.serials[serial] || crl[serial] {
+				return errors.New("tls: the certificate is revoked, serial: " + serial)
+			}
+		}
+	}
+	return nil
+}
+
+//choose the certificate by the sni
+type tlsServerCerts struct {
+	def *tlsKeyPair
+	sni []tlsSniCert
+}
+
+type tlsSniCert struct {
+	names []string
+	pair  *tlsKeyPair
+}
+
+//tls.Config.GetCertificate
+func (c *tlsServerCerts) get(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if name := strings.ToLower(hello.ServerName); len(name) > 0 {
+		for i := range c.sni {
+			if cert, err := c.sni[i].pair.get(); err == nil && c.sni[i].match(name, cert) {
+				return cert, nil
+			}
+		}
+	}
+	return c.def.get()
+}
+
+func (c *tlsSniCert) match(name string, cert *tls.Certificate) bool {
+	if len(c.names) < 1 {
+		return cert.Leaf != nil && cert.Leaf.VerifyHostname(name) == nil
+	}
+	for _, it := range c.names {
+		it = strings.ToLower(it)
+		if it == name {
+			return true
+		}
+		if strings.HasPrefix(it, "*.") && strings.HasSuffix(name, it[1:]) { //the wildcard matches one label only
+			if label := name[:len(name)-len(it)+1]; len(label) > 0 && !strings.Contains(label, ".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//make the tls config for every handshake, so the root ca and the client certificate are the current ones
+type tlsClientCreds struct {
+	serverName string
+	opts       *tlsOptions
+	pool       *tlsCaPool
+	pair       *tlsKeyPair //nil if no client certificate
+}
+
+func (c *tlsClientCreds) config() (*tls.Config, error) {
+	cas, err := c.pool.get()
+	if err != nil {
+		return nil, err
+	}
+	res := &tls.Config{
+		ServerName:   c.serverName,
+		RootCAs:      cas,
+		MinVersion:   c.opts.minVersion,
+		CipherSuites: c.opts.ciphers,
+		NextProtos:   c.opts.alpn,
+	}
+	if c.opts.revoked != nil {
+		res.VerifyPeerCertificate = c.opts.revoked.verify
+	}
+	if c.pair != nil {
+		res.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return c.pair.get()
+		}
+	}
+	return res, nil
+}
+
+func (c *tlsClientCreds) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conf, err := c.config()
+	if err != nil {
+		return nil, nil, err
+	}
+	return credentials.NewTLS(conf).ClientHandshake(ctx, authority, rawConn)
+}
+
+func (c *tlsClientCreds) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("the client credentials do not support the server handshake")
+}
+
+func (c *tlsClientCreds) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{
+		SecurityProtocol: "tls",
+		SecurityVersion:  "1.2",
+		ServerName:       c.serverName,
+	}
+}
+
+func (c *tlsClientCreds) Clone() credentials.TransportCredentials {
+	t := *c
+	return &t
+}
+
+func (c *tlsClientCreds) OverrideServerName(serverNameOverride string) error {
+	c.serverName = serverNameOverride
+	return nil
+}
@@ -0,0 +1,310 @@
+// Scry Info.  All rights reserved.
+// license that can be found in the license file.
+
+package tlsdot
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/scryinfo/dot/dot"
+	"github.com/scryinfo/dot/dots/certificate"
+	"github.com/scryinfo/dot/dots/line"
+	"google.golang.org/grpc/credentials"
+)
+
+//make the ca, server, other(server) and client certificates into the dir, the mod time is changed to make sure the reloading
+func makeTestCerts(t *testing.T, dir string, modTime time.Time) (*x509.Certificate, *ecdsa.PrivateKey) {
+	ec := &certificate.Ecdsa{}
+	caPri, _ := certificate.MakePriKey()
+	ca, err := ec.GenerateCaCertKey(caPri, filepath.Join(dir, "ca.key"), filepath.Join(dir, "ca.pem"), []string{"scry"}, []string{"scry"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"server", "other", "client"} {
+		if err = ec.GenerateCertKey(ca, caPri, filepath.Join(dir, name+".key"), filepath.Join(dir, name+".pem"), []string{name + ".scry"}, []string{"scry"}); err != nil {
+			t.Fatal(err)
+		}
+		_ = os.Chtimes(filepath.Join(dir, name+".pem"), modTime, modTime)
+		_ = os.Chtimes(filepath.Join(dir, name+".key"), modTime, modTime)
+	}
+	_ = os.Chtimes(filepath.Join(dir, "ca.pem"), modTime, modTime)
+	return ca, caPri
+}
+
+//handshake with the tls server, return the dns name of the server certificate
+func testHandshake(serverConf *tls.Config, creds credentials.TransportCredentials) (string, error) {
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", serverConf)
+	if err != nil {
+		return "", err
+	}
+	defer lis.Close()
+	go func() {
+		conn, err := lis.Accept()
+		if err == nil {
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+	conn, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	tc, info, err := creds.ClientHandshake(context.Background(), lis.Addr().String(), conn)
+	if err != nil {
+		return "", err
+	}
+	//the server verify the client certificate after the client handshake, read to get the result
+	_ = tc.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err = tc.Read(make([]byte, 1)); err != nil && err != io.EOF {
+		return "", err
+	}
+	return info.(credentials.TLSInfo).State.PeerCertificates[0].DNSNames[0], nil
+}
+
+func TestTls_Reload(t *testing.T) {
+	old := tlsReloadInterval
+	tlsReloadInterval = 0
+	defer func() { tlsReloadInterval = old }()
+
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	now := time.Now()
+	makeTestCerts(t, dir, now.Add(-time.Hour))
+
+	tl := New()
+	serverConf, err := tl.ServerConfig(TlsConfig{CaPem: filepath.Join(dir, "ca.pem"), Pem: filepath.Join(dir, "server.pem"), Key: filepath.Join(dir, "server.key")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds, err := tl.ClientCredentials(TlsConfig{CaPem: filepath.Join(dir, "ca.pem"), Pem: filepath.Join(dir, "client.pem"), Key: filepath.Join(dir, "client.key"), ServerNameOverride: "server.scry"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", serverConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	clientNames := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			tc := conn.(*tls.Conn)
+			name := ""
+			if err = tc.Handshake(); err == nil && len(tc.ConnectionState().VerifiedChains) > 0 {
+				name = tc.ConnectionState().VerifiedChains[0][0].DNSNames[0]
+			}
+			clientNames <- name
+			_ = conn.Close()
+		}
+	}()
+
+	handshake := func() []byte {
+		conn, err := net.Dial("tcp", lis.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		_, info, err := creds.ClientHandshake(context.Background(), lis.Addr().String(), conn)
+		if name := <-clientNames; err != nil || name != "client.scry" {
+			t.Fatal(name, err)
+		}
+		return info.(credentials.TLSInfo).State.PeerCertificates[0].Raw
+	}
+
+	first := handshake()
+	if second := handshake(); string(first) != string(second) {
+		t.Error("the certificate is changed without the files changing")
+	}
+
+	makeTestCerts(t, dir, now) //new ca, the old certificates can not be verified
+	if third := handshake(); string(first) == string(third) {
+		t.Error("the certificate is not reloaded")
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "server.key"), []byte("bad"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Chtimes(filepath.Join(dir, "server.key"), now.Add(time.Hour), now.Add(time.Hour))
+	handshake() //the broken key is not loaded, keep the old one
+
+	if c, err := tl.ServerConfig(TlsConfig{Pem: filepath.Join(dir, "none.pem"), Key: filepath.Join(dir, "server.key")}); err == nil || c != nil {
+		t.Error("no error for the file is not existed")
+	}
+	if c, err := tl.ServerConfig(TlsConfig{}); err != nil || c != nil {
+		t.Error(c, err)
+	}
+}
+
+func TestTls_Mode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	makeTestCerts(t, dir, time.Now())
+	ca, pem, key := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+
+	tl := New()
+	servers := []struct {
+		conf TlsConfig
+		ok   bool
+	}{
+		{TlsConfig{Mode: TlsModeNone}, true},
+		{TlsConfig{Mode: TlsModeServer, Pem: pem, Key: key, MinVersion: "1.2", CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}}, true},
+		{TlsConfig{Mode: TlsModeMutual, Pem: pem, Key: key, CaPem: ca, SystemRoots: true}, true},
+		{TlsConfig{Mode: TlsModeNone, Pem: pem, Key: key}, false},
+		{TlsConfig{Mode: "both", Pem: pem, Key: key}, false},
+		{TlsConfig{Mode: TlsModeServer, Pem: pem}, false},
+		{TlsConfig{Mode: TlsModeServer, Pem: pem, Key: key, CaPem: ca}, false},
+		{TlsConfig{Mode: TlsModeMutual, Pem: pem, Key: key}, false},
+		{TlsConfig{Mode: TlsModeMutual, Pem: pem, Key: key, SystemRoots: true, Crl: filepath.Join(dir, "ca.crl")}, false},
+		{TlsConfig{Pem: pem, Key: key, MinVersion: "2.0"}, false},
+		{TlsConfig{Pem: pem, Key: key, CipherSuites: []string{"TLS_NONE"}}, false},
+		{TlsConfig{Pem: pem, Key: key, MinVersion: "1.3", CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}}, false},
+		{TlsConfig{Pem: pem, Key: key, CaPem: ca, RevokedSerials: []string{"0xzz"}}, false},
+	}
+	for i, it := range servers {
+		if _, err := tl.ServerConfig(it.conf); (err == nil) != it.ok {
+			t.Error("server", i, err)
+		}
+	}
+
+	clients := []struct {
+		conf TlsConfig
+		ok   bool
+	}{
+		{TlsConfig{}, true},
+		{TlsConfig{Mode: TlsModeServer, SystemRoots: true}, true},
+		{TlsConfig{Mode: TlsModeServer, CaPem: ca, SystemRoots: true}, true},
+		{TlsConfig{Mode: TlsModeMutual, CaPem: ca, Pem: pem, Key: key}, true},
+		{TlsConfig{Mode: TlsModeServer}, false},
+		{TlsConfig{Mode: TlsModeServer, CaPem: ca, Pem: pem}, false},
+		{TlsConfig{Mode: TlsModeServer, Pem: pem, Key: key}, false},
+		{TlsConfig{Mode: TlsModeMutual, CaPem: ca, Pem: pem}, false},
+		{TlsConfig{Mode: TlsModeMutual, Pem: pem, Key: key}, false},
+		{TlsConfig{Mode: TlsModeServer, CaPem: ca, SniCerts: []TlsCert{{Pem: pem, Key: key}}}, false},
+	}
+	for i, it := range clients {
+		if _, err := tl.ClientCredentials(it.conf); (err == nil) != it.ok {
+			t.Error("client", i, err)
+		}
+	}
+}
+
+func TestTls_SniAndRevoked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ca, caPri := makeTestCerts(t, dir, time.Now())
+	file := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+	ec := &certificate.Ecdsa{}
+	serial := func(name string) *big.Int {
+		cert, err := ec.Certificate(file(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert.SerialNumber
+	}
+
+	tl := New()
+	serverConf := TlsConfig{Mode: TlsModeMutual, CaPem: file("ca.pem"), Pem: file("server.pem"), Key: file("server.key"),
+		SniCerts: []TlsCert{{Pem: file("other.pem"), Key: file("other.key")}}, Alpn: []string{"h2"}}
+	clientConf := TlsConfig{Mode: TlsModeMutual, CaPem: file("ca.pem"), Pem: file("client.pem"), Key: file("client.key")}
+	handshake := func(serverName string) (string, error) {
+		sc, err := tl.ServerConfig(serverConf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cc := clientConf
+		cc.ServerNameOverride = serverName
+		creds, err := tl.ClientCredentials(cc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return testHandshake(sc, creds)
+	}
+
+	for _, name := range []string{"server.scry", "other.scry"} {
+		if got, err := handshake(name); err != nil || got != name {
+			t.Error(name, got, err)
+		}
+	}
+	sni := tlsSniCert{names: []string{"*.other.scry", "Alias.scry"}}
+	for name, want := range map[string]bool{"a.other.scry": true, "alias.scry": true, "other.scry": false, "a.b.other.scry": false} {
+		if sni.match(name, nil) != want {
+			t.Error(name)
+		}
+	}
+
+	serverConf.SniCerts = nil
+	clientConf.RevokedSerials = []string{"0x" + serial("server.pem").Text(16)}
+	if _, err = handshake("server.scry"); err == nil {
+		t.Error("the revoked server certificate is accepted")
+	}
+	clientConf.RevokedSerials = nil
+
+	if err = ec.GenerateCrl(ca, caPri, file("ca.crl"), []*big.Int{serial("client.pem")}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	serverConf.Crl = file("ca.crl")
+	if _, err = handshake("server.scry"); err == nil {
+		t.Error("the revoked client certificate is accepted")
+	}
+
+	otherPri, _ := certificate.MakePriKey()
+	otherCa, err := ec.GenerateCaCertKey(otherPri, file("other-ca.key"), file("other-ca.pem"), []string{"scry"}, []string{"scry"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ec.GenerateCrl(otherCa, otherPri, file("other.crl"), nil, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	serverConf.Crl = file("other.crl")
+	if _, err = tl.ServerConfig(serverConf); err == nil {
+		t.Error("the crl is not signed by the ca")
+	}
+}
+
+func TestTls_Get(t *testing.T) {
+	l, err := line.BuildAndStart(func(l dot.Line) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.ToLifer().Stop(true) //do not destroy, the logger of the line is used by the other tests
+
+	tl := Get(l)
+	if tl == nil || Get(l) != tl {
+		t.Error("the line has more than one tls")
+	}
+	if d, err := l.ToInjecter().GetByType(reflect.TypeOf(tl)); err != nil || d != tl {
+		t.Error("the tls is not injected by type", err)
+	}
+	if Get(nil) == tl || Get(nil) == Get(nil) {
+		t.Error("the tls without line is shared")
+	}
+}
@@ -12,6 +12,6 @@ require (
 	github.com/scryinfo/scryg v0.1.3-0.20190608053141-a292b801bfd6
 	go.uber.org/zap v1.10.0
 	golang.org/x/tools v0.0.0-20190808195139-e713427fea3f
-	google.golang.org/grpc v1.22.1
+	google.golang.org/grpc v1.27.1
 	gopkg.in/yaml.v2 v2.2.2
 )
@@ -10,7 +10,7 @@ require (
 	go.uber.org/zap v1.10.0
 	google.golang.org/appengine v1.4.0 // indirect
 	google.golang.org/genproto v0.0.0-20190620144150-6af8c5fc6601 // indirect
-	google.golang.org/grpc v1.21.1
+	google.golang.org/grpc v1.27.1
 )
 
 replace (
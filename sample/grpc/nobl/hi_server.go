@@ -9,6 +9,7 @@ import (
 	"github.com/scryinfo/dot/dots/grpc/gserver"
 	"github.com/scryinfo/dot/sample/grpc/go_out/hidot"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -51,8 +52,9 @@ func (serv *HiServer) Hi(ctx context.Context, req *hidot.ReqData) (*hidot.ResDat
 	return res, nil
 }
 
-func (serv *HiServer) Start(ignore bool) error {
-	hidot.RegisterHiDotServer(serv.ServerNobl.Server(), serv)
+//RegisterGrpc see gserver.GrpcRegister
+func (serv *HiServer) RegisterGrpc(s *grpc.Server) error {
+	hidot.RegisterHiDotServer(s, serv)
 	return nil
 }
 